@@ -0,0 +1,36 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/axiomesh/guardian/core"
+	"github.com/urfave/cli/v2"
+)
+
+var auditCMD = &cli.Command{
+	Name:  "audit",
+	Usage: "The audit log of upgrades manage commands",
+	Subcommands: []*cli.Command{
+		{
+			Name:   "verify",
+			Usage:  "Verify the hash chain of the audit log, an edited, removed or truncated entry fails the check",
+			Action: verifyAudit,
+		},
+	},
+}
+
+func verifyAudit(ctx *cli.Context) error {
+	p, err := getRootPath(ctx)
+	if err != nil {
+		return err
+	}
+
+	entries, err := core.VerifyAuditLog(p)
+	if err != nil {
+		// exit with non zero status for scripts
+		return cli.Exit(fmt.Sprintf("audit log verification failed after %d valid entries: %s", entries, err), 1)
+	}
+
+	fmt.Printf("audit log is intact, %d entries\n", entries)
+	return nil
+}
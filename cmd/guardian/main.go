@@ -28,9 +28,21 @@ func main() {
 
 	app.Commands = []*cli.Command{
 		configCMD,
+		restoreCMD,
+		upgradeCMD,
+		policyCMD,
+		statusCMD,
+		rescanCMD,
+		auditCMD,
 		{
-			Name:   "start",
-			Usage:  "Start a long-running daemon process",
+			Name:  "start",
+			Usage: "Start a long-running daemon process",
+			Flags: []cli.Flag{
+				&cli.BoolFlag{
+					Name:  "dry-run",
+					Usage: "Download and verify releases, but only log and record the restart instead of doing it",
+				},
+			},
 			Action: start,
 		},
 		{
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/axiomesh/guardian/core"
+	"github.com/axiomesh/guardian/repo"
+	"github.com/urfave/cli/v2"
+)
+
+var policyCMD = &cli.Command{
+	Name:  "policy",
+	Usage: "The local upgrade policy manage commands, a running guardian reloads the policy after every change",
+	Subcommands: []*cli.Command{
+		{
+			Name:   "show",
+			Usage:  "Show the local upgrade policy",
+			Action: showPolicy,
+		},
+		{
+			Name:      "pin",
+			Usage:     "Pin axiom to a version, only an upgrade to it is allowed",
+			ArgsUsage: "<version>",
+			Action: editPolicy(func(p *repo.Policy, arg string) {
+				p.PinnedVersion = arg
+			}),
+		},
+		{
+			Name:  "unpin",
+			Usage: "Remove the pinned version",
+			Action: editPolicy(func(p *repo.Policy, _ string) {
+				p.PinnedVersion = ""
+			}),
+		},
+		{
+			Name:      "deny",
+			Usage:     "Deny a version or a sha256 digest of release package, patch or binary",
+			ArgsUsage: "<version|digest>",
+			Action: editPolicy(func(p *repo.Policy, arg string) {
+				if core.IsDigest(arg) {
+					p.DeniedDigests = appendUnique(p.DeniedDigests, arg)
+				} else {
+					p.DeniedVersions = appendUnique(p.DeniedVersions, arg)
+				}
+			}),
+		},
+		{
+			Name:      "undeny",
+			Usage:     "Remove a denied version or digest",
+			ArgsUsage: "<version|digest>",
+			Action: editPolicy(func(p *repo.Policy, arg string) {
+				p.DeniedVersions = remove(p.DeniedVersions, arg)
+				p.DeniedDigests = remove(p.DeniedDigests, arg)
+			}),
+		},
+		{
+			Name:      "allow-range",
+			Usage:     "Add an allowed version range, e.g. \">=1.2.0 <2.0.0\"",
+			ArgsUsage: "<constraint>",
+			Action: editPolicy(func(p *repo.Policy, arg string) {
+				p.AllowedRanges = appendUnique(p.AllowedRanges, arg)
+			}),
+		},
+		{
+			Name:      "remove-range",
+			Usage:     "Remove an allowed version range",
+			ArgsUsage: "<constraint>",
+			Action: editPolicy(func(p *repo.Policy, arg string) {
+				p.AllowedRanges = remove(p.AllowedRanges, arg)
+			}),
+		},
+	},
+}
+
+func showPolicy(ctx *cli.Context) error {
+	p, err := getRootPath(ctx)
+	if err != nil {
+		return err
+	}
+	r, err := repo.Load(p)
+	if err != nil {
+		return err
+	}
+
+	str, err := repo.MarshalConfig(r.Config.Policy)
+	if err != nil {
+		return err
+	}
+	fmt.Print(str)
+	return nil
+}
+
+// editPolicy changes the policy in the config file only if the result is valid, then reloads the running guardian
+func editPolicy(edit func(p *repo.Policy, arg string)) cli.ActionFunc {
+	return func(ctx *cli.Context) error {
+		if ctx.Command.ArgsUsage != "" && ctx.NArg() != 1 {
+			return fmt.Errorf("%s is required", ctx.Command.ArgsUsage)
+		}
+
+		p, err := getRootPath(ctx)
+		if err != nil {
+			return err
+		}
+		r, err := repo.Load(p)
+		if err != nil {
+			return err
+		}
+
+		edit(&r.Config.Policy, ctx.Args().First())
+		if _, err := core.NewPolicy(r.Config.Policy); err != nil {
+			return fmt.Errorf("invalid policy: %w", err)
+		}
+		if err := r.Flush(); err != nil {
+			return err
+		}
+		fmt.Println("policy updated")
+
+		client, err := newControlClient(ctx)
+		if err != nil {
+			return err
+		}
+		if err := client.ReloadPolicy(ctx.Context); err != nil {
+			fmt.Printf("running guardian not reloaded: %s, the policy applies on next start\n", err)
+			return nil
+		}
+		fmt.Println("policy reloaded by running guardian")
+		return nil
+	}
+}
+
+func appendUnique(list []string, s string) []string {
+	for _, item := range list {
+		if item == s {
+			return list
+		}
+	}
+	return append(list, s)
+}
+
+func remove(list []string, s string) []string {
+	res := make([]string, 0, len(list))
+	for _, item := range list {
+		if item != s {
+			res = append(res, item)
+		}
+	}
+	return res
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/axiomesh/axiom-kit/log"
+	"github.com/axiomesh/guardian/core"
+	"github.com/axiomesh/guardian/repo"
+	"github.com/urfave/cli/v2"
+)
+
+var restoreCMD = &cli.Command{
+	Name:      "restore",
+	Usage:     "Restore axiom data directory and binary from a snapshot, axiom must be stopped",
+	ArgsUsage: "[snapshot id]",
+	Description: "Put back the data directory and the matching axiom binary of the snapshot. " +
+		"The snapshots are listed if no snapshot id is given.",
+	Action: restore,
+}
+
+func restore(ctx *cli.Context) error {
+	p, err := getRootPath(ctx)
+	if err != nil {
+		return err
+	}
+	r, err := repo.Load(p)
+	if err != nil {
+		return err
+	}
+
+	sm, err := core.NewSnapshotManager(r.Config, log.NewWithModule("snapshot"))
+	if err != nil {
+		return err
+	}
+
+	if ctx.NArg() == 0 {
+		metas, err := sm.List()
+		if err != nil {
+			return err
+		}
+		if len(metas) == 0 {
+			fmt.Println("no snapshot")
+			return nil
+		}
+		for _, meta := range metas {
+			fmt.Printf("%s\tversion: %s\tproposal: %d\tmode: %s\tcreated at: %s\n",
+				meta.ID, meta.Version, meta.ProposalID, meta.Mode, time.Unix(meta.CreatedAt, 0).Format(time.RFC3339))
+		}
+		return nil
+	}
+
+	meta, err := sm.Restore(ctx.Args().First())
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("snapshot %s restored to %s with axiom version %s, please start axiom\n", meta.ID, meta.DataPath, meta.Version)
+	return nil
+}
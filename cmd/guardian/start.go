@@ -2,7 +2,6 @@ package main
 
 import (
 	"fmt"
-	_ "net/http/pprof"
 	"os"
 	"os/signal"
 	"path/filepath"
@@ -13,7 +12,6 @@ import (
 	"github.com/axiomesh/guardian"
 	"github.com/axiomesh/guardian/core"
 	"github.com/axiomesh/guardian/repo"
-	"github.com/ethereum/go-ethereum/ethclient"
 	"github.com/urfave/cli/v2"
 )
 
@@ -26,6 +24,9 @@ func start(ctx *cli.Context) error {
 	if err != nil {
 		return err
 	}
+	if ctx.Bool("dry-run") {
+		r.Config.DryRun = true
+	}
 
 	err = log.Initialize(
 		log.WithReportCaller(r.Config.Log.ReportCaller),
@@ -41,21 +42,22 @@ func start(ctx *cli.Context) error {
 
 	printVersion()
 
-	client, err := ethclient.DialContext(ctx.Context, r.Config.DialUrl)
-	if err != nil {
-		return err
-	}
-
-	guardian, err := core.NewGuardian(ctx.Context, r.Config, client)
+	guardian, err := core.NewGuardian(ctx.Context, r.Config, core.NewEthClientFactory(r.Config.DialUrl))
 	if err != nil {
 		return fmt.Errorf("new guardian error: %w", err)
 	}
 
 	var wg sync.WaitGroup
 	wg.Add(1)
-	handleShutdown(guardian, &wg)
+	detachShutdown := handleShutdown(guardian, &wg)
+	handleStateDump(guardian)
 
 	if err := guardian.Start(); err != nil {
+		// the supervisor may have spawned axiom already, it must not outlive the guardian
+		detachShutdown()
+		if err := guardian.Stop(); err != nil {
+			fmt.Printf("stop guardian error: %s\n", err)
+		}
 		return fmt.Errorf("start guardian failed: %w", err)
 	}
 
@@ -74,18 +76,53 @@ func printVersion() {
 	fmt.Println()
 }
 
-func handleShutdown(node *core.Guardian, wg *sync.WaitGroup) {
+// handleShutdown stops the guardian and exits on a signal or once the guardian stops itself,
+// the returned func detaches the handler when the caller stops the guardian itself
+func handleShutdown(node *core.Guardian, wg *sync.WaitGroup) func() {
 	var stop = make(chan os.Signal, 2)
 	signal.Notify(stop, syscall.SIGTERM)
 	signal.Notify(stop, syscall.SIGINT)
+	detached := make(chan struct{})
 
 	go func() {
-		<-stop
-		fmt.Println("received interrupt signal, shutting down...")
+		code := 0
+		select {
+		case <-detached:
+			signal.Stop(stop)
+			return
+		case <-stop:
+			fmt.Println("received interrupt signal, shutting down...")
+		case <-node.Ctx.Done():
+			// guardian stops itself on fatal errors, e.g. axiom is found on another network
+			fmt.Println("guardian stopped, shutting down...")
+			code = 1
+		}
 		if err := node.Stop(); err != nil {
-			panic(err)
+			fmt.Printf("stop guardian error: %s\n", err)
+			os.Exit(1)
 		}
 		wg.Done()
-		os.Exit(0)
+		os.Exit(code)
+	}()
+
+	return func() {
+		close(detached)
+	}
+}
+
+// handleStateDump writes a state snapshot with the stacks of all goroutines into the logs directory on SIGUSR1
+func handleStateDump(node *core.Guardian) {
+	var dump = make(chan os.Signal, 1)
+	signal.Notify(dump, syscall.SIGUSR1)
+
+	go func() {
+		for range dump {
+			path, err := node.WriteStateSnapshot()
+			if err != nil {
+				node.Logger.Errorf("write state snapshot error: %s", err)
+				continue
+			}
+			node.Logger.Infof("state snapshot written to %s", path)
+		}
 	}()
 }
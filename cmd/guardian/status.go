@@ -0,0 +1,83 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/urfave/cli/v2"
+)
+
+var statusCMD = &cli.Command{
+	Name:   "status",
+	Usage:  "Show what the running guardian is doing",
+	Action: showStatus,
+}
+
+var rescanCMD = &cli.Command{
+	Name:      "rescan",
+	Usage:     "Handle the proposal logs from a block again",
+	ArgsUsage: "<from block>",
+	Action:    rescan,
+}
+
+func showStatus(ctx *cli.Context) error {
+	client, err := newControlClient(ctx)
+	if err != nil {
+		return err
+	}
+
+	status, err := client.Status(ctx.Context)
+	if err != nil {
+		return err
+	}
+
+	if status.VersionError != "" {
+		fmt.Printf("axiom version: unknown (%s)\n", status.VersionError)
+	} else {
+		fmt.Printf("axiom version: %s\n", status.CurrentVersion)
+	}
+	if status.RPC.Error != "" {
+		fmt.Printf("rpc: %s, error: %s\n", status.RPC.State, status.RPC.Error)
+	} else {
+		fmt.Printf("rpc: %s, block: %d\n", status.RPC.State, status.RPC.BlockNumber)
+	}
+	fmt.Printf("checkpoint block: %d\n", status.Checkpoint)
+	fmt.Printf("upgrades paused: %t\n", status.Worker.Paused)
+	for _, queued := range status.Worker.Queued {
+		fmt.Printf("queued proposal: %d\tversion: %s\n", queued.ProposalID, queued.Version)
+	}
+	if upgrade := status.Upgrade; upgrade != nil {
+		fmt.Printf("upgrade: proposal %d\t%s -> %s\tstate: %s\n", upgrade.ProposalID, upgrade.FromVersion, upgrade.ToVersion, upgrade.State)
+	} else {
+		fmt.Println("upgrade: none")
+	}
+	if staged := status.Staged; staged != nil {
+		fmt.Printf("staged: proposal %d\tversion: %s\trelease: %s\n", staged.ProposalID, staged.Version, staged.ReleasePath)
+	}
+	if pending := status.PendingApproval; pending != nil {
+		fmt.Printf("awaiting approval: proposal %d\n", pending.ProposalID)
+	}
+	return nil
+}
+
+func rescan(ctx *cli.Context) error {
+	if ctx.NArg() != 1 {
+		return fmt.Errorf("from block is required")
+	}
+	fromBlock, err := strconv.ParseUint(ctx.Args().First(), 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid from block: %w", err)
+	}
+
+	client, err := newControlClient(ctx)
+	if err != nil {
+		return err
+	}
+
+	result, err := client.Rescan(ctx.Context, fromBlock)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("rescan from block %d handled %d logs\n", result.FromBlock, result.Logs)
+	return nil
+}
@@ -0,0 +1,211 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/axiomesh/guardian/core"
+	"github.com/axiomesh/guardian/repo"
+	"github.com/urfave/cli/v2"
+)
+
+var upgradeCMD = &cli.Command{
+	Name:  "upgrade",
+	Usage: "Inspect and decide upgrades of the running guardian",
+	Subcommands: []*cli.Command{
+		{
+			Name:   "queue",
+			Usage:  "Show the proposals queued for the upgrade worker",
+			Action: upgradeQueue,
+		},
+		{
+			Name:   "pending",
+			Usage:  "Show the upgrade awaiting approval",
+			Action: pendingUpgrade,
+		},
+		{
+			Name:      "approve",
+			Usage:     "Approve the restart of the upgrade awaiting approval",
+			ArgsUsage: "<proposal id>",
+			Action: func(ctx *cli.Context) error {
+				return decideUpgrade(ctx, true)
+			},
+		},
+		{
+			Name:      "reject",
+			Usage:     "Reject the upgrade awaiting approval, the proposal is not applied",
+			ArgsUsage: "<proposal id>",
+			Action: func(ctx *cli.Context) error {
+				return decideUpgrade(ctx, false)
+			},
+		},
+		{
+			Name:      "abort",
+			Usage:     "Abort the upgrade in progress before axiom is restarted, the proposal is not applied",
+			ArgsUsage: "<proposal id>",
+			Action:    abortUpgrade,
+		},
+		{
+			Name:  "pause",
+			Usage: "Stop applying upgrades after the one in progress, approved proposals are still queued",
+			Action: func(ctx *cli.Context) error {
+				return pauseUpgrades(ctx, true)
+			},
+		},
+		{
+			Name:  "resume",
+			Usage: "Apply the upgrades queued while paused",
+			Action: func(ctx *cli.Context) error {
+				return pauseUpgrades(ctx, false)
+			},
+		},
+	},
+}
+
+func newControlClient(ctx *cli.Context) (*core.ControlClient, error) {
+	p, err := getRootPath(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	token, err := core.LoadAdminToken(p)
+	if err != nil {
+		return nil, err
+	}
+
+	return core.NewControlClient(filepath.Join(p, repo.ControlSocketName), token), nil
+}
+
+func upgradeQueue(ctx *cli.Context) error {
+	client, err := newControlClient(ctx)
+	if err != nil {
+		return err
+	}
+
+	status, err := client.Queue(ctx.Context)
+	if err != nil {
+		return err
+	}
+
+	if status.Busy {
+		fmt.Println("upgrade worker is busy")
+	} else {
+		fmt.Println("upgrade worker is idle")
+	}
+	if status.Paused {
+		fmt.Println("upgrades are paused")
+	}
+	if len(status.Queued) == 0 {
+		fmt.Println("no queued proposal")
+		return nil
+	}
+	for _, queued := range status.Queued {
+		version := queued.Version
+		if version == "" {
+			version = "unknown version"
+		}
+		fmt.Printf("proposal: %d\tversion: %s\tqueued at: %s\n", queued.ProposalID, version, time.Unix(queued.QueuedAt, 0).Format(time.RFC3339))
+	}
+	return nil
+}
+
+func pendingUpgrade(ctx *cli.Context) error {
+	client, err := newControlClient(ctx)
+	if err != nil {
+		return err
+	}
+
+	pending, err := client.Pending(ctx.Context)
+	if err != nil {
+		return err
+	}
+	if pending == nil {
+		fmt.Println("no upgrade awaiting approval")
+		return nil
+	}
+
+	fmt.Printf("proposal: %d\tversion: %s -> %s\trelease: %s\tsince: %s\n",
+		pending.ProposalID, pending.FromVersion, pending.ToVersion, pending.ReleasePath, time.Unix(pending.Since, 0).Format(time.RFC3339))
+	if pending.AutoApproveAt != 0 {
+		fmt.Printf("approved automatically at %s\n", time.Unix(pending.AutoApproveAt, 0).Format(time.RFC3339))
+	}
+	return nil
+}
+
+func parseProposalID(ctx *cli.Context) (uint64, error) {
+	if ctx.NArg() != 1 {
+		return 0, fmt.Errorf("proposal id is required")
+	}
+	proposalID, err := strconv.ParseUint(ctx.Args().First(), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid proposal id: %w", err)
+	}
+	return proposalID, nil
+}
+
+func decideUpgrade(ctx *cli.Context, approve bool) error {
+	proposalID, err := parseProposalID(ctx)
+	if err != nil {
+		return err
+	}
+
+	client, err := newControlClient(ctx)
+	if err != nil {
+		return err
+	}
+
+	if approve {
+		if err := client.Approve(ctx.Context, proposalID); err != nil {
+			return err
+		}
+		fmt.Printf("upgrade by proposal %d approved\n", proposalID)
+		return nil
+	}
+
+	if err := client.Reject(ctx.Context, proposalID); err != nil {
+		return err
+	}
+	fmt.Printf("upgrade by proposal %d rejected\n", proposalID)
+	return nil
+}
+
+func abortUpgrade(ctx *cli.Context) error {
+	proposalID, err := parseProposalID(ctx)
+	if err != nil {
+		return err
+	}
+
+	client, err := newControlClient(ctx)
+	if err != nil {
+		return err
+	}
+
+	if err := client.Abort(ctx.Context, proposalID); err != nil {
+		return err
+	}
+	fmt.Printf("abort of upgrade by proposal %d requested\n", proposalID)
+	return nil
+}
+
+func pauseUpgrades(ctx *cli.Context, pause bool) error {
+	client, err := newControlClient(ctx)
+	if err != nil {
+		return err
+	}
+
+	if pause {
+		if err := client.Pause(ctx.Context); err != nil {
+			return err
+		}
+		fmt.Println("upgrades paused")
+		return nil
+	}
+
+	if err := client.Resume(ctx.Context); err != nil {
+		return err
+	}
+	fmt.Println("upgrades resumed")
+	return nil
+}
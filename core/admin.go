@@ -0,0 +1,194 @@
+package core
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/axiomesh/guardian/repo"
+	"github.com/ethereum/go-ethereum"
+)
+
+const rpcProbeTimeout = 5 * time.Second
+
+var (
+	ErrNotAbortable   = errors.New("upgrade can not be aborted")
+	ErrUpgradeAborted = errors.New("aborted by operator")
+)
+
+// AdminStatus is what the guardian is doing, reported by the admin api
+type AdminStatus struct {
+	CurrentVersion string
+	// VersionError is set if the installed version can not be read
+	VersionError string `json:",omitempty"`
+	// Worker shows the proposals queued for the upgrade worker and if upgrades are paused
+	Worker          *WorkerStatus
+	PendingApproval *PendingApproval
+	// Upgrade is the upgrade in progress, nil if none
+	Upgrade *UpgradeRecord
+	// Staged is the verified release of the upgrade in progress waiting for restart, nil if none
+	Staged     *StagedRelease
+	Checkpoint uint64
+	RPC        RPCHealth
+}
+
+type StagedRelease struct {
+	ProposalID  uint64
+	Version     string
+	ReleasePath string
+}
+
+type RPCHealth struct {
+	State       string
+	BlockNumber uint64
+	Error       string `json:",omitempty"`
+}
+
+// Status collects the admin status, the rpc of axiom is probed once
+func (g *Guardian) Status(ctx context.Context) *AdminStatus {
+	status := &AdminStatus{
+		Worker:          g.WorkerStatus(),
+		PendingApproval: g.PendingApproval(),
+		Upgrade:         g.ActiveUpgrade(),
+		Checkpoint:      g.Checkpoint(),
+	}
+
+	if version, err := g.getAxiomLedgerCurrentVersion(g.installedBinaryPath()); err != nil {
+		status.VersionError = err.Error()
+	} else {
+		status.CurrentVersion = version.String()
+	}
+
+	if upgrade := status.Upgrade; upgrade != nil {
+		switch upgrade.State {
+		case StateVerified, StateAwaitingApproval, StateStaged:
+			status.Staged = &StagedRelease{
+				ProposalID:  upgrade.ProposalID,
+				Version:     upgrade.ToVersion,
+				ReleasePath: upgrade.ReleasePath,
+			}
+		}
+	}
+
+	status.RPC.State = g.ConnState().String()
+	probeCtx, cancel := context.WithTimeout(ctx, rpcProbeTimeout)
+	defer cancel()
+	if client := g.getClient(); client == nil {
+		status.RPC.Error = ErrNotConnected.Error()
+	} else if height, err := client.BlockNumber(probeCtx); err != nil {
+		status.RPC.Error = err.Error()
+	} else {
+		status.RPC.BlockNumber = height
+	}
+
+	return status
+}
+
+// AbortUpgrade stops the upgrade by proposal before axiom is restarted, the proposal is not applied again.
+// An upgrade awaiting approval is aborted at once, otherwise the upgrade worker aborts at the end of the stage in progress.
+func (g *Guardian) AbortUpgrade(proposalID uint64) error {
+	if err := g.Reject(proposalID); err == nil {
+		return nil
+	}
+
+	g.active.lock.Lock()
+	defer g.active.lock.Unlock()
+
+	upgrade := g.active.record
+	if upgrade == nil || upgrade.ProposalID != proposalID {
+		return fmt.Errorf("%w: proposal %d is not upgrading", ErrNotAbortable, proposalID)
+	}
+	if upgrade.State.restarted() {
+		return fmt.Errorf("%w: proposal %d is in state %s", ErrNotAbortable, proposalID, upgrade.State)
+	}
+	g.active.abort = true
+	g.Logger.Warnf("abort of upgrade by proposal %d requested in state %s", proposalID, upgrade.State)
+
+	return nil
+}
+
+// abortIfRequested ends the upgrade as failed if an operator aborted it, the staged release is removed
+func (g *Guardian) abortIfRequested(upgrade *UpgradeRecord, releasePath string) bool {
+	g.active.lock.Lock()
+	abort := g.active.abort
+	g.active.lock.Unlock()
+	if !abort {
+		return false
+	}
+
+	g.Logger.Warnf("upgrade by proposal %d aborted by operator", upgrade.ProposalID)
+	if releasePath != "" {
+		_ = os.RemoveAll(releasePath)
+	}
+	g.recordUpgradeProposal(upgrade.ProposalID)
+	g.transition(upgrade, StateFailed, ErrUpgradeAborted)
+	return true
+}
+
+// Rescan handles the proposal logs from fromBlock again and returns how many are found,
+// proposals already applied are skipped by the planner
+func (g *Guardian) Rescan(ctx context.Context, fromBlock uint64) (int, error) {
+	client := g.getClient()
+	if client == nil {
+		return 0, ErrNotConnected
+	}
+	logs, err := client.FilterLogs(ctx, ethereum.FilterQuery{
+		FromBlock: new(big.Int).SetUint64(fromBlock),
+		ToBlock:   g.ToBlock,
+		Addresses: g.Addresses,
+		Topics:    g.Topics,
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	g.Logger.Infof("rescan from block %d finds %d logs", fromBlock, len(logs))
+	for i := range logs {
+		g.handleProposalLog(&logs[i])
+	}
+
+	return len(logs), nil
+}
+
+// LoadAdminToken reads the admin api token of the guardian with repoRoot
+func LoadAdminToken(repoRoot string) (string, error) {
+	data, err := os.ReadFile(filepath.Join(repoRoot, repo.AdminTokenName))
+	if err != nil {
+		return "", fmt.Errorf("read admin token: %w", err)
+	}
+
+	token := strings.TrimSpace(string(data))
+	if token == "" {
+		return "", errors.New("admin token is empty")
+	}
+	return token, nil
+}
+
+// loadOrCreateAdminToken generates a random token readable only by the user running guardian if there is none
+func loadOrCreateAdminToken(repoRoot string) (string, error) {
+	token, err := LoadAdminToken(repoRoot)
+	if err == nil {
+		return token, nil
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		return "", err
+	}
+
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	token = hex.EncodeToString(raw)
+	if err := os.WriteFile(filepath.Join(repoRoot, repo.AdminTokenName), []byte(token+"\n"), 0600); err != nil {
+		return "", fmt.Errorf("write admin token: %w", err)
+	}
+
+	return token, nil
+}
@@ -0,0 +1,113 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"path/filepath"
+	"testing"
+
+	"github.com/axiomesh/guardian/repo"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAdminAPI(t *testing.T) {
+	c := repo.DefaultConfig(t.TempDir())
+	c.Admin.ListenAddr = "127.0.0.1:0"
+	guardian, err := NewGuardian(context.Background(), c, StaticClientFactory(&MockClient{}))
+	assert.Nil(t, err)
+	assert.Nil(t, guardian.control.Start())
+	defer guardian.control.Stop()
+
+	token, err := LoadAdminToken(c.RepoRoot)
+	assert.Nil(t, err)
+	assert.Len(t, token, 64)
+
+	// the tcp listener requires the token as well
+	statusURL := "http://" + guardian.control.Addr() + "/status"
+	resp, err := http.Get(statusURL)
+	assert.Nil(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+	req, err := http.NewRequest(http.MethodGet, statusURL, nil)
+	assert.Nil(t, err)
+	req.Header.Set("Authorization", "Bearer "+token)
+	resp, err = http.DefaultClient.Do(req)
+	assert.Nil(t, err)
+	status := &AdminStatus{}
+	assert.Nil(t, json.NewDecoder(resp.Body).Decode(status))
+	resp.Body.Close()
+	assert.Equal(t, "connected", status.RPC.State)
+	assert.NotZero(t, status.RPC.BlockNumber)
+	assert.NotEmpty(t, status.VersionError)
+	assert.Nil(t, status.Upgrade)
+
+	socket := filepath.Join(c.RepoRoot, repo.ControlSocketName)
+	ctx := context.Background()
+	assert.ErrorContains(t, NewControlClient(socket, "wrong").Pause(ctx), "invalid admin token")
+
+	client := NewControlClient(socket, token)
+	assert.Nil(t, client.Pause(ctx))
+	status, err = client.Status(ctx)
+	assert.Nil(t, err)
+	assert.True(t, status.Worker.Paused)
+	assert.Nil(t, client.Resume(ctx))
+	assert.False(t, guardian.WorkerStatus().Paused)
+
+	// the mock client returns one upgrade proposal log
+	result, err := client.Rescan(ctx, 1)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, result.Logs)
+	assert.Equal(t, uint64(1), guardian.WorkerStatus().Queued[0].ProposalID)
+
+	assert.ErrorContains(t, client.Abort(ctx, 1), "can not be aborted")
+}
+
+func TestAbortUpgrade(t *testing.T) {
+	c := repo.DefaultConfig(t.TempDir())
+	guardian, err := NewGuardian(context.Background(), c, StaticClientFactory(&MockClient{}))
+	assert.Nil(t, err)
+
+	current := &Version{Major: 1}
+	upgrade := guardian.beginUpgrade(&NodeProposal{BaseProposal: BaseProposal{ID: 1}, Version: "1.1.0"}, current)
+	guardian.transition(upgrade, StateDownloading, nil)
+	assert.Equal(t, StateDownloading, guardian.ActiveUpgrade().State)
+	assert.False(t, guardian.abortIfRequested(upgrade, ""))
+
+	assert.ErrorIs(t, guardian.AbortUpgrade(2), ErrNotAbortable)
+	assert.Nil(t, guardian.AbortUpgrade(1))
+	assert.True(t, guardian.abortIfRequested(upgrade, ""))
+	assert.Equal(t, StateFailed, upgrade.State)
+	assert.Equal(t, ErrUpgradeAborted.Error(), upgrade.Error)
+	assert.Nil(t, guardian.ActiveUpgrade())
+	lastID, applied := guardian.getLastUpgradeProposal()
+	assert.True(t, applied)
+	assert.Equal(t, uint64(1), lastID)
+
+	// axiom may be stopped already
+	upgrade = guardian.beginUpgrade(&NodeProposal{BaseProposal: BaseProposal{ID: 2}, Version: "1.2.0"}, current)
+	guardian.transition(upgrade, StateRestarting, nil)
+	assert.ErrorIs(t, guardian.AbortUpgrade(2), ErrNotAbortable)
+}
+
+func TestCheckpoint(t *testing.T) {
+	c := repo.DefaultConfig(t.TempDir())
+	guardian, err := NewGuardian(context.Background(), c, StaticClientFactory(&MockClient{}))
+	assert.Nil(t, err)
+
+	assert.Zero(t, guardian.Checkpoint())
+	guardian.updateCheckpoint(10)
+	guardian.updateCheckpoint(5)
+	assert.Equal(t, uint64(10), guardian.Checkpoint())
+	assert.Equal(t, uint64(10), guardian.getNewestFromBlock().Uint64())
+}
+
+func TestCheckLoopback(t *testing.T) {
+	assert.Nil(t, checkLoopback(""))
+	assert.Nil(t, checkLoopback("127.0.0.1:9401"))
+	assert.Nil(t, checkLoopback("[::1]:9401"))
+	assert.Nil(t, checkLoopback("localhost:9401"))
+	assert.NotNil(t, checkLoopback("0.0.0.0:9401"))
+	assert.NotNil(t, checkLoopback(":9401"))
+}
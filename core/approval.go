@@ -0,0 +1,105 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+var ErrNotAwaitingApproval = errors.New("proposal is not awaiting approval")
+
+// PendingApproval is a verified release waiting for an operator to approve the restart
+type PendingApproval struct {
+	ProposalID  uint64
+	FromVersion string
+	ToVersion   string
+	ReleasePath string
+	Since       int64
+	// AutoApproveAt is the unix time the restart is approved automatically, 0 means never
+	AutoApproveAt int64
+}
+
+type approvalGate struct {
+	lock     sync.Mutex
+	pending  *PendingApproval
+	decision chan bool
+}
+
+// awaitApproval blocks in the AwaitingApproval state until the operator decides or the auto approve timeout expires
+func (g *Guardian) awaitApproval(pending *PendingApproval) (bool, error) {
+	timeout := g.Config.Approval.AutoApproveTimeout
+	pending.Since = time.Now().Unix()
+	if timeout > 0 {
+		pending.AutoApproveAt = time.Now().Add(timeout).Unix()
+	}
+
+	decision := make(chan bool, 1)
+	g.approval.lock.Lock()
+	g.approval.pending = pending
+	g.approval.decision = decision
+	g.approval.lock.Unlock()
+
+	defer func() {
+		g.approval.lock.Lock()
+		g.approval.pending = nil
+		g.approval.decision = nil
+		g.approval.lock.Unlock()
+	}()
+
+	g.Logger.Warnf("upgrade by proposal %d to version %s is awaiting approval, run `guardian upgrade approve %d` or `guardian upgrade reject %d`",
+		pending.ProposalID, pending.ToVersion, pending.ProposalID, pending.ProposalID)
+
+	var autoApprove <-chan time.Time
+	if timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		autoApprove = timer.C
+	}
+
+	select {
+	case approved := <-decision:
+		return approved, nil
+	case <-autoApprove:
+		g.Logger.Infof("upgrade by proposal %d approved automatically after %s", pending.ProposalID, timeout)
+		return true, nil
+	case <-g.Ctx.Done():
+		return false, g.Ctx.Err()
+	}
+}
+
+// Approve lets the upgrade by proposal awaiting approval go on with the restart
+func (g *Guardian) Approve(proposalID uint64) error {
+	return g.decide(proposalID, true)
+}
+
+// Reject drops the upgrade by proposal awaiting approval, the proposal is not applied again
+func (g *Guardian) Reject(proposalID uint64) error {
+	return g.decide(proposalID, false)
+}
+
+func (g *Guardian) decide(proposalID uint64, approved bool) error {
+	g.approval.lock.Lock()
+	defer g.approval.lock.Unlock()
+
+	if g.approval.pending == nil || g.approval.pending.ProposalID != proposalID {
+		return fmt.Errorf("%w: %d", ErrNotAwaitingApproval, proposalID)
+	}
+
+	g.approval.decision <- approved
+	// only the first decision counts
+	g.approval.pending = nil
+	return nil
+}
+
+// PendingApproval returns the upgrade awaiting approval, nil if none
+func (g *Guardian) PendingApproval() *PendingApproval {
+	g.approval.lock.Lock()
+	defer g.approval.lock.Unlock()
+
+	if g.approval.pending == nil {
+		return nil
+	}
+	pending := *g.approval.pending
+	return &pending
+}
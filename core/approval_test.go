@@ -0,0 +1,60 @@
+package core
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/axiomesh/guardian/repo"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAwaitApproval(t *testing.T) {
+	c := repo.DefaultConfig(t.TempDir())
+	c.Approval.Enable = true
+	guardian, err := NewGuardian(context.Background(), c, StaticClientFactory(&MockClient{}))
+	assert.Nil(t, err)
+	assert.Nil(t, guardian.control.Start())
+	defer guardian.control.Stop()
+
+	token, err := LoadAdminToken(c.RepoRoot)
+	assert.Nil(t, err)
+	client := NewControlClient(filepath.Join(c.RepoRoot, repo.ControlSocketName), token)
+	ctx := context.Background()
+
+	pending, err := client.Pending(ctx)
+	assert.Nil(t, err)
+	assert.Nil(t, pending)
+	assert.ErrorContains(t, client.Approve(ctx, 2), "not awaiting approval")
+
+	await := func(proposalID uint64) chan bool {
+		result := make(chan bool, 1)
+		go func() {
+			approved, err := guardian.awaitApproval(&PendingApproval{ProposalID: proposalID, FromVersion: "1.0.0", ToVersion: "1.1.0"})
+			assert.Nil(t, err)
+			result <- approved
+		}()
+		assert.Eventually(t, func() bool { return guardian.PendingApproval() != nil }, time.Second, 10*time.Millisecond)
+		return result
+	}
+
+	result := await(2)
+	pending, err = client.Pending(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(2), pending.ProposalID)
+	assert.Equal(t, "1.1.0", pending.ToVersion)
+	assert.Zero(t, pending.AutoApproveAt)
+	assert.ErrorContains(t, client.Approve(ctx, 3), "not awaiting approval")
+	assert.Nil(t, client.Approve(ctx, 2))
+	assert.True(t, <-result)
+	assert.Nil(t, guardian.PendingApproval())
+
+	result = await(3)
+	assert.Nil(t, client.Reject(ctx, 3))
+	assert.False(t, <-result)
+
+	guardian.Config.Approval.AutoApproveTimeout = 50 * time.Millisecond
+	result = await(4)
+	assert.True(t, <-result)
+}
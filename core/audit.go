@@ -0,0 +1,323 @@
+package core
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/axiomesh/guardian/repo"
+)
+
+var ErrAuditTampered = errors.New("audit log is tampered")
+
+// AuditEntry records which proposal caused which binary to be installed, and how the upgrade ended
+type AuditEntry struct {
+	Time       int64
+	ProposalID uint64
+	// TxHash is the transaction emitting the proposal log, empty for an upgrade resumed after restart
+	TxHash string
+	// PackageDigest is the sha256 of the release package declared by the proposal
+	PackageDigest string
+	// BinaryDigest is the sha256 of the axiom binary of the verified release, empty if it is not verified
+	BinaryDigest string
+	FromVersion  string
+	ToVersion    string
+	Hooks        []*HookResult
+	Outcome      UpgradeState
+	Error        string
+}
+
+// auditRecord is a line of the audit log, Hash covers Seq, PrevHash and the exact bytes of Entry
+type auditRecord struct {
+	Seq      uint64
+	PrevHash string
+	Hash     string
+	Entry    json.RawMessage
+}
+
+// AuditHead is the last entry of the audit log, kept apart from the log so truncation is detected
+type AuditHead struct {
+	Seq  uint64
+	Hash string
+}
+
+// AuditLog appends entries to the hash chained audit log
+type AuditLog struct {
+	lock     sync.Mutex
+	path     string
+	headPath string
+	head     AuditHead
+	// err is set if the log does not end at the head, nothing is appended then
+	err error
+}
+
+// OpenAuditLog opens the audit log under repoRoot. A crash after an entry is synced but before the head is moved
+// leaves the log one entry ahead, the head is moved to it. Any other mismatch is kept as Err, so the log is never
+// appended after a tampered tail.
+func OpenAuditLog(repoRoot string) (*AuditLog, error) {
+	a := &AuditLog{
+		path:     filepath.Join(repoRoot, repo.AuditLogName),
+		headPath: filepath.Join(repoRoot, repo.AuditHeadName),
+	}
+
+	head, err := readAuditHead(a.headPath)
+	if err != nil {
+		return nil, err
+	}
+	if head != nil {
+		a.head = *head
+	}
+
+	last, err := readLastAuditRecord(a.path)
+	if err != nil {
+		if !errors.Is(err, ErrAuditTampered) {
+			return nil, err
+		}
+		a.err = err
+		return a, nil
+	}
+
+	var lastHead AuditHead
+	if last != nil {
+		lastHead = AuditHead{Seq: last.Seq, Hash: last.Hash}
+	}
+	switch {
+	case lastHead == a.head:
+	case last != nil && last.Seq == a.head.Seq+1 && last.PrevHash == a.head.Hash && last.Hash == auditHash(last):
+		if err := writeAuditHead(a.headPath, &lastHead); err != nil {
+			return nil, err
+		}
+		a.head = lastHead
+	default:
+		a.err = fmt.Errorf("%w: log ends at entry %d, head is at entry %d", ErrAuditTampered, lastHead.Seq, a.head.Seq)
+	}
+
+	return a, nil
+}
+
+// Err returns why the log is not appended, nil if it is
+func (a *AuditLog) Err() error {
+	return a.err
+}
+
+// Append writes entry after the last one and moves the head to it
+func (a *AuditLog) Append(entry *AuditEntry) error {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	if a.err != nil {
+		return a.err
+	}
+
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	record := &auditRecord{
+		Seq:      a.head.Seq + 1,
+		PrevHash: a.head.Hash,
+		Entry:    data,
+	}
+	record.Hash = auditHash(record)
+	line, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(a.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	head := AuditHead{Seq: record.Seq, Hash: record.Hash}
+	if err := writeAuditHead(a.headPath, &head); err != nil {
+		return err
+	}
+	a.head = head
+
+	return nil
+}
+
+// VerifyAuditLog checks the hash chain of the audit log under repoRoot against its head,
+// it returns the number of entries, an edited, removed or truncated entry is reported as ErrAuditTampered
+func VerifyAuditLog(repoRoot string) (uint64, error) {
+	head, err := readAuditHead(filepath.Join(repoRoot, repo.AuditHeadName))
+	if err != nil {
+		return 0, err
+	}
+
+	f, err := os.Open(filepath.Join(repoRoot, repo.AuditLogName))
+	if err != nil {
+		if os.IsNotExist(err) && head == nil {
+			return 0, nil
+		}
+		if os.IsNotExist(err) {
+			return 0, fmt.Errorf("%w: log is missing, head is at entry %d", ErrAuditTampered, head.Seq)
+		}
+		return 0, err
+	}
+	defer f.Close()
+
+	var last AuditHead
+	reader := bufio.NewReader(f)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err == io.EOF {
+			if len(line) != 0 {
+				return last.Seq, fmt.Errorf("%w: entry %d is truncated", ErrAuditTampered, last.Seq+1)
+			}
+			break
+		}
+		if err != nil {
+			return last.Seq, err
+		}
+
+		record := &auditRecord{}
+		if err := json.Unmarshal(line, record); err != nil {
+			return last.Seq, fmt.Errorf("%w: entry %d: %s", ErrAuditTampered, last.Seq+1, err)
+		}
+		if record.Seq != last.Seq+1 {
+			return last.Seq, fmt.Errorf("%w: entry %d follows entry %d", ErrAuditTampered, record.Seq, last.Seq)
+		}
+		if record.PrevHash != last.Hash {
+			return last.Seq, fmt.Errorf("%w: entry %d does not link to entry %d", ErrAuditTampered, record.Seq, last.Seq)
+		}
+		if record.Hash != auditHash(record) {
+			return last.Seq, fmt.Errorf("%w: entry %d is edited", ErrAuditTampered, record.Seq)
+		}
+		last = AuditHead{Seq: record.Seq, Hash: record.Hash}
+	}
+
+	if head == nil {
+		if last.Seq != 0 {
+			return last.Seq, fmt.Errorf("%w: head is missing", ErrAuditTampered)
+		}
+		return 0, nil
+	}
+	if last != *head {
+		return last.Seq, fmt.Errorf("%w: log ends at entry %d, head is at entry %d", ErrAuditTampered, last.Seq, head.Seq)
+	}
+
+	return last.Seq, nil
+}
+
+// readLastAuditRecord returns the last entry of the audit log, nil if there is none
+func readLastAuditRecord(path string) (*auditRecord, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if len(data) == 0 {
+		return nil, nil
+	}
+	if data[len(data)-1] != '\n' {
+		return nil, fmt.Errorf("%w: last entry is truncated", ErrAuditTampered)
+	}
+
+	data = data[:len(data)-1]
+	line := data[bytes.LastIndexByte(data, '\n')+1:]
+	record := &auditRecord{}
+	if err := json.Unmarshal(line, record); err != nil {
+		return nil, fmt.Errorf("%w: last entry: %s", ErrAuditTampered, err)
+	}
+	return record, nil
+}
+
+func auditHash(record *auditRecord) string {
+	h := sha256.New()
+	h.Write([]byte(strconv.FormatUint(record.Seq, 10)))
+	h.Write([]byte{'\n'})
+	h.Write([]byte(record.PrevHash))
+	h.Write([]byte{'\n'})
+	h.Write(record.Entry)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// readAuditHead returns nil if there is no head
+func readAuditHead(path string) (*AuditHead, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	head := &AuditHead{}
+	if err := json.Unmarshal(data, head); err != nil {
+		return nil, fmt.Errorf("%w: invalid head: %s", ErrAuditTampered, err)
+	}
+	return head, nil
+}
+
+// writeAuditHead replaces the head by rename, it is never half written
+func writeAuditHead(path string, head *AuditHead) error {
+	data, err := json.Marshal(head)
+	if err != nil {
+		return err
+	}
+
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, path)
+}
+
+// newUpgradeAudit starts the audit entry of the upgrade by proposal, it is completed as the upgrade goes on
+func newUpgradeAudit(proposal *NodeProposal, currentVersion *Version) *AuditEntry {
+	return &AuditEntry{
+		ProposalID:    proposal.ID,
+		TxHash:        proposal.TxHash,
+		PackageDigest: proposal.CheckHash,
+		FromVersion:   currentVersion.String(),
+		ToVersion:     proposal.Version,
+	}
+}
+
+// auditUpgrade appends the finished upgrade to the audit log if enabled, a failure is only logged
+func (g *Guardian) auditUpgrade(upgrade *UpgradeRecord) {
+	if g.audit == nil {
+		return
+	}
+
+	entry := g.upgradeAudit
+	if entry == nil || entry.ProposalID != upgrade.ProposalID {
+		// resumed after restart, only the upgrade record is left
+		entry = &AuditEntry{ProposalID: upgrade.ProposalID, FromVersion: upgrade.FromVersion}
+	}
+	entry.Time = time.Now().Unix()
+	if upgrade.ToVersion != "" {
+		entry.ToVersion = upgrade.ToVersion
+	}
+	entry.Outcome = upgrade.State
+	entry.Error = upgrade.Error
+
+	if err := g.audit.Append(entry); err != nil {
+		g.Logger.Errorf("append audit log of upgrade by proposal %d error: %s", upgrade.ProposalID, err)
+	}
+}
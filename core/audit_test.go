@@ -0,0 +1,165 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/axiomesh/guardian/repo"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAuditLog(t *testing.T) {
+	dir := t.TempDir()
+	entries, err := VerifyAuditLog(dir)
+	assert.Nil(t, err)
+	assert.Zero(t, entries)
+
+	audit, err := OpenAuditLog(dir)
+	assert.Nil(t, err)
+	for _, version := range []string{"1.1.0", "1.2.0", "1.3.0"} {
+		assert.Nil(t, audit.Append(&AuditEntry{ToVersion: version, Outcome: StateDone}))
+	}
+
+	// the chain goes on after reopen
+	audit, err = OpenAuditLog(dir)
+	assert.Nil(t, err)
+	assert.Nil(t, audit.Append(&AuditEntry{ToVersion: "1.4.0", Outcome: StateFailed}))
+	entries, err = VerifyAuditLog(dir)
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(4), entries)
+
+	logPath := filepath.Join(dir, repo.AuditLogName)
+	original, err := os.ReadFile(logPath)
+	assert.Nil(t, err)
+	lines := bytes.SplitAfter(original, []byte("\n"))
+	tamper := func(data []byte) error {
+		assert.Nil(t, os.WriteFile(logPath, data, 0600))
+		_, err := VerifyAuditLog(dir)
+		assert.ErrorIs(t, err, ErrAuditTampered)
+		return err
+	}
+
+	assert.ErrorContains(t, tamper(bytes.Replace(original, []byte("1.2.0"), []byte("1.9.0"), 1)), "entry 2 is edited")
+	assert.ErrorContains(t, tamper(bytes.Join(lines[:3], nil)), "log ends at entry 3, head is at entry 4")
+	assert.ErrorContains(t, tamper(bytes.Join([][]byte{lines[0], lines[2], lines[3]}, nil)), "entry 3 follows entry 1")
+	assert.ErrorContains(t, tamper(original[:len(original)-10]), "entry 4 is truncated")
+
+	// a rewritten entry does not link to the next one
+	record := &auditRecord{}
+	assert.Nil(t, json.Unmarshal(lines[1], record))
+	record.Entry = json.RawMessage(bytes.Replace(record.Entry, []byte("1.2.0"), []byte("1.9.0"), 1))
+	record.Hash = auditHash(record)
+	forged, err := json.Marshal(record)
+	assert.Nil(t, err)
+	assert.ErrorContains(t, tamper(bytes.Join([][]byte{lines[0], append(forged, '\n'), lines[2], lines[3]}, nil)), "entry 3 does not link to entry 2")
+
+	assert.Nil(t, os.WriteFile(logPath, original, 0600))
+	_, err = VerifyAuditLog(dir)
+	assert.Nil(t, err)
+}
+
+func TestAuditLogCrashBeforeHead(t *testing.T) {
+	dir := t.TempDir()
+	audit, err := OpenAuditLog(dir)
+	assert.Nil(t, err)
+	assert.Nil(t, audit.Append(&AuditEntry{ToVersion: "1.1.0", Outcome: StateDone}))
+	headPath := filepath.Join(dir, repo.AuditHeadName)
+	oldHead, err := os.ReadFile(headPath)
+	assert.Nil(t, err)
+	assert.Nil(t, audit.Append(&AuditEntry{ToVersion: "1.2.0", Outcome: StateDone}))
+
+	// crashed after the second entry is synced, before the head is moved
+	assert.Nil(t, os.WriteFile(headPath, oldHead, 0600))
+	_, err = VerifyAuditLog(dir)
+	assert.ErrorContains(t, err, "log ends at entry 2, head is at entry 1")
+
+	audit, err = OpenAuditLog(dir)
+	assert.Nil(t, err)
+	assert.Nil(t, audit.Err())
+	assert.Nil(t, audit.Append(&AuditEntry{ToVersion: "1.3.0", Outcome: StateDone}))
+	entries, err := VerifyAuditLog(dir)
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(3), entries)
+
+	// crashed before the first head is written
+	first := t.TempDir()
+	audit, err = OpenAuditLog(first)
+	assert.Nil(t, err)
+	assert.Nil(t, audit.Append(&AuditEntry{ToVersion: "1.1.0", Outcome: StateDone}))
+	assert.Nil(t, os.Remove(filepath.Join(first, repo.AuditHeadName)))
+	audit, err = OpenAuditLog(first)
+	assert.Nil(t, err)
+	assert.Nil(t, audit.Err())
+	entries, err = VerifyAuditLog(first)
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(1), entries)
+
+	// any other mismatch is never appended after
+	logPath := filepath.Join(dir, repo.AuditLogName)
+	data, err := os.ReadFile(logPath)
+	assert.Nil(t, err)
+	lines := bytes.SplitAfter(data, []byte("\n"))
+	assert.Nil(t, os.WriteFile(logPath, bytes.Join(lines[:1], nil), 0600))
+	audit, err = OpenAuditLog(dir)
+	assert.Nil(t, err)
+	assert.ErrorIs(t, audit.Err(), ErrAuditTampered)
+	assert.ErrorIs(t, audit.Append(&AuditEntry{ToVersion: "1.4.0"}), ErrAuditTampered)
+	assert.Nil(t, os.WriteFile(logPath, data[:len(data)-10], 0600))
+	audit, err = OpenAuditLog(dir)
+	assert.Nil(t, err)
+	assert.ErrorContains(t, audit.Err(), "last entry is truncated")
+}
+
+func TestAuditUpgrade(t *testing.T) {
+	c := repo.DefaultConfig(t.TempDir())
+	guardian, err := NewGuardian(context.Background(), c, StaticClientFactory(&MockClient{}))
+	assert.Nil(t, err)
+
+	proposal := &NodeProposal{
+		BaseProposal: BaseProposal{ID: 3},
+		CheckHash:    "596d31575d39232ac8b80522e74d7e2c85ce177a0936a38f9616b8ceef3e97d1",
+		Version:      "1.1.0",
+		TxHash:       "0x01",
+	}
+	guardian.upgradeAudit = newUpgradeAudit(proposal, &Version{Major: 1})
+	guardian.upgradeAudit.Hooks = append(guardian.upgradeAudit.Hooks, &HookResult{Name: "drain", Phase: HookPreRestart})
+	upgrade := guardian.beginUpgrade(proposal, &Version{Major: 1})
+	guardian.transition(upgrade, StateDownloading, nil)
+	guardian.failUpgrade(upgrade, errors.New("download error"))
+
+	// resumed upgrades have no audit entry in progress
+	guardian.upgradeAudit = nil
+	upgrade = guardian.beginUpgrade(&NodeProposal{BaseProposal: BaseProposal{ID: 4}}, &Version{Major: 1})
+	guardian.transition(upgrade, StateDone, nil)
+
+	entries, err := VerifyAuditLog(c.RepoRoot)
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(2), entries)
+
+	data, err := os.ReadFile(filepath.Join(c.RepoRoot, repo.AuditLogName))
+	assert.Nil(t, err)
+	record := &auditRecord{}
+	assert.Nil(t, json.Unmarshal(bytes.SplitAfter(data, []byte("\n"))[0], record))
+	entry := &AuditEntry{}
+	assert.Nil(t, json.Unmarshal(record.Entry, entry))
+	assert.Equal(t, uint64(3), entry.ProposalID)
+	assert.Equal(t, "0x01", entry.TxHash)
+	assert.Equal(t, proposal.CheckHash, entry.PackageDigest)
+	assert.Equal(t, "1.0.0", entry.FromVersion)
+	assert.Equal(t, "1.1.0", entry.ToVersion)
+	assert.Equal(t, "drain", entry.Hooks[0].Name)
+	assert.Equal(t, StateFailed, entry.Outcome)
+	assert.Equal(t, "download error", entry.Error)
+
+	// nothing is installed in dry run mode
+	c = repo.DefaultConfig(t.TempDir())
+	c.DryRun = true
+	guardian, err = NewGuardian(context.Background(), c, StaticClientFactory(&MockClient{}))
+	assert.Nil(t, err)
+	assert.Nil(t, guardian.audit)
+}
@@ -0,0 +1,109 @@
+package core
+
+import (
+	"bytes"
+	"compress/bzip2"
+	"errors"
+	"fmt"
+	"io"
+)
+
+const (
+	// bsdiffMagic is the header of patches produced by bsdiff 4.x
+	bsdiffMagic = "BSDIFF40"
+
+	// maxPatchGrowth caps the patched size at this multiple of the old size, the size is read from the patch
+	// before any digest check, so a hostile patch must not make us allocate whatever it declares
+	maxPatchGrowth = 4
+	// minPatchSizeLimit keeps the cap usable for small old files
+	minPatchSizeLimit = 64 << 20
+)
+
+var ErrCorruptPatch = errors.New("corrupt patch")
+
+// bspatch applies a bsdiff 4.x patch to old and returns the new content.
+//
+// The patch layout is a 32 bytes header (magic, control block length, diff block length, new size)
+// followed by three bzip2 compressed blocks: control, diff and extra.
+func bspatch(old, patch []byte) ([]byte, error) {
+	if len(patch) < 32 || string(patch[:8]) != bsdiffMagic {
+		return nil, fmt.Errorf("%w: bad header", ErrCorruptPatch)
+	}
+
+	ctrlLen := offtin(patch[8:16])
+	diffLen := offtin(patch[16:24])
+	newSize := offtin(patch[24:32])
+	// check the lengths one by one, their sum may overflow
+	bodyLen := int64(len(patch)) - 32
+	if ctrlLen < 0 || diffLen < 0 || newSize < 0 || ctrlLen > bodyLen || diffLen > bodyLen-ctrlLen {
+		return nil, fmt.Errorf("%w: bad header", ErrCorruptPatch)
+	}
+	if limit := patchSizeLimit(len(old)); newSize > limit {
+		return nil, fmt.Errorf("%w: new size %d exceeds limit %d", ErrCorruptPatch, newSize, limit)
+	}
+
+	ctrlReader := bzip2.NewReader(bytes.NewReader(patch[32 : 32+ctrlLen]))
+	diffReader := bzip2.NewReader(bytes.NewReader(patch[32+ctrlLen : 32+ctrlLen+diffLen]))
+	extraReader := bzip2.NewReader(bytes.NewReader(patch[32+ctrlLen+diffLen:]))
+
+	newData := make([]byte, newSize)
+	oldSize := int64(len(old))
+	var oldPos, newPos int64
+	buf := make([]byte, 8)
+	for newPos < newSize {
+		var ctrl [3]int64
+		for i := range ctrl {
+			if _, err := io.ReadFull(ctrlReader, buf); err != nil {
+				return nil, fmt.Errorf("%w: read control block: %s", ErrCorruptPatch, err)
+			}
+			ctrl[i] = offtin(buf)
+		}
+
+		// add diff bytes to old data
+		if ctrl[0] < 0 || newPos+ctrl[0] > newSize {
+			return nil, fmt.Errorf("%w: diff exceeds new size", ErrCorruptPatch)
+		}
+		if _, err := io.ReadFull(diffReader, newData[newPos:newPos+ctrl[0]]); err != nil {
+			return nil, fmt.Errorf("%w: read diff block: %s", ErrCorruptPatch, err)
+		}
+		for i := int64(0); i < ctrl[0]; i++ {
+			if oldPos+i >= 0 && oldPos+i < oldSize {
+				newData[newPos+i] += old[oldPos+i]
+			}
+		}
+		newPos += ctrl[0]
+		oldPos += ctrl[0]
+
+		// copy extra bytes
+		if ctrl[1] < 0 || newPos+ctrl[1] > newSize {
+			return nil, fmt.Errorf("%w: extra exceeds new size", ErrCorruptPatch)
+		}
+		if _, err := io.ReadFull(extraReader, newData[newPos:newPos+ctrl[1]]); err != nil {
+			return nil, fmt.Errorf("%w: read extra block: %s", ErrCorruptPatch, err)
+		}
+		newPos += ctrl[1]
+		oldPos += ctrl[2]
+	}
+
+	return newData, nil
+}
+
+func patchSizeLimit(oldSize int) int64 {
+	limit := int64(oldSize) * maxPatchGrowth
+	if limit < minPatchSizeLimit {
+		return minPatchSizeLimit
+	}
+	return limit
+}
+
+// offtin decodes the sign-magnitude little endian integer used by bsdiff
+func offtin(buf []byte) int64 {
+	y := int64(buf[7] & 0x7f)
+	for i := 6; i >= 0; i-- {
+		y = y<<8 | int64(buf[i])
+	}
+	if buf[7]&0x80 != 0 {
+		y = -y
+	}
+	return y
+}
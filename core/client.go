@@ -3,22 +3,80 @@ package core
 import (
 	"context"
 	"encoding/json"
+	"math/big"
+	"sync/atomic"
 
 	"github.com/axiomesh/guardian/repo"
 	"github.com/ethereum/go-ethereum"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
 )
 
 type Client interface {
 	FilterLogs(ctx context.Context, q ethereum.FilterQuery) ([]types.Log, error)
 
 	SubscribeFilterLogs(context.Context, ethereum.FilterQuery, chan<- types.Log) (ethereum.Subscription, error)
+
+	BlockNumber(ctx context.Context) (uint64, error)
+
+	PeerCount(ctx context.Context) (uint64, error)
+
+	// ClientVersion returns the node version reported by web3_clientVersion
+	ClientVersion(ctx context.Context) (string, error)
+
+	ChainID(ctx context.Context) (*big.Int, error)
+
+	CodeAt(ctx context.Context, account common.Address, blockNumber *big.Int) ([]byte, error)
+}
+
+var (
+	_ Client = (*EthClient)(nil)
+	_ Client = (*MockClient)(nil)
+)
+
+// ClientFactory creates a connected Client, it is called again on every reconnect
+type ClientFactory func(ctx context.Context) (Client, error)
+
+// NewEthClientFactory returns a ClientFactory dials the axiom rpc endpoint rawurl
+func NewEthClientFactory(rawurl string) ClientFactory {
+	return func(ctx context.Context) (Client, error) {
+		return DialClient(ctx, rawurl)
+	}
 }
 
-var _ Client = (*MockClient)(nil)
+// StaticClientFactory returns a ClientFactory always returns client
+func StaticClientFactory(client Client) ClientFactory {
+	return func(ctx context.Context) (Client, error) {
+		return client, nil
+	}
+}
+
+// EthClient is the Client of an axiom rpc endpoint
+type EthClient struct {
+	*ethclient.Client
+}
+
+func DialClient(ctx context.Context, rawurl string) (*EthClient, error) {
+	client, err := ethclient.DialContext(ctx, rawurl)
+	if err != nil {
+		return nil, err
+	}
+
+	return &EthClient{Client: client}, nil
+}
+
+func (ec *EthClient) ClientVersion(ctx context.Context) (string, error) {
+	var version string
+	if err := ec.Client.Client().CallContext(ctx, &version, "web3_clientVersion"); err != nil {
+		return "", err
+	}
+
+	return version, nil
+}
 
 type MockClient struct {
+	blockNumber uint64
 }
 
 func (mc *MockClient) FilterLogs(ctx context.Context, q ethereum.FilterQuery) ([]types.Log, error) {
@@ -36,6 +94,27 @@ func (mc *MockClient) SubscribeFilterLogs(context.Context, ethereum.FilterQuery,
 	return &MockSubscription{}, nil
 }
 
+// BlockNumber advances on every call
+func (mc *MockClient) BlockNumber(ctx context.Context) (uint64, error) {
+	return 1000 + atomic.AddUint64(&mc.blockNumber, 1), nil
+}
+
+func (mc *MockClient) PeerCount(ctx context.Context) (uint64, error) {
+	return 3, nil
+}
+
+func (mc *MockClient) ClientVersion(ctx context.Context) (string, error) {
+	return "axiom/v0.0.1/linux-amd64/go1.20.5", nil
+}
+
+func (mc *MockClient) ChainID(ctx context.Context) (*big.Int, error) {
+	return big.NewInt(1356), nil
+}
+
+func (mc *MockClient) CodeAt(ctx context.Context, account common.Address, blockNumber *big.Int) ([]byte, error) {
+	return []byte("node manager"), nil
+}
+
 func generateLog() (*types.Log, error) {
 	nodeProposal := &NodeProposal{
 		BaseProposal: BaseProposal{
@@ -0,0 +1,186 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// ErrNotConnected is returned before guardian first connects to axiom
+var ErrNotConnected = errors.New("not connected to axiom")
+
+type ConnState int32
+
+const (
+	ConnDisconnected ConnState = iota
+	ConnConnecting
+	ConnConnected
+)
+
+func (s ConnState) String() string {
+	switch s {
+	case ConnDisconnected:
+		return "disconnected"
+	case ConnConnecting:
+		return "connecting"
+	case ConnConnected:
+		return "connected"
+	default:
+		return "unknown"
+	}
+}
+
+// connection tracks the connection state to axiom, waiters are woken by closing changed
+type connection struct {
+	lock    sync.Mutex
+	state   ConnState
+	changed chan struct{}
+}
+
+func newConnection() *connection {
+	return &connection{changed: make(chan struct{})}
+}
+
+func (c *connection) set(state ConnState) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if c.state == state {
+		return
+	}
+	c.state = state
+	close(c.changed)
+	c.changed = make(chan struct{})
+}
+
+func (c *connection) get() (ConnState, <-chan struct{}) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	return c.state, c.changed
+}
+
+// ConnState returns the connection state to axiom
+func (g *Guardian) ConnState() ConnState {
+	state, _ := g.conn.get()
+	return state
+}
+
+// WaitConnected blocks until guardian is connected to axiom or ctx is done
+func (g *Guardian) WaitConnected(ctx context.Context) error {
+	for {
+		state, changed := g.conn.get()
+		if state == ConnConnected {
+			return nil
+		}
+
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// getClient returns nil before guardian first connects to axiom
+func (g *Guardian) getClient() Client {
+	g.clientLock.RLock()
+	defer g.clientLock.RUnlock()
+
+	return g.Client
+}
+
+// reconnect dials a new client and subscribes logs again, it retries until it succeeds or ctx is done.
+// The log listener retries as long as guardian runs, the upgrade worker bounds ctx by the health check timeout,
+// so an axiom never coming back fails the health check.
+// Guardian stops itself if axiom turns out to be on another network, retrying would not change that.
+func (g *Guardian) reconnect(ctx context.Context) error {
+	// only one reconnect at a time, the upgrade worker and the log listener may both find the connection lost
+	select {
+	case g.reconnecting <- struct{}{}:
+		defer func() { <-g.reconnecting }()
+	case <-ctx.Done():
+		return fmt.Errorf("wait for reconnect in progress: %w", ctx.Err())
+	}
+
+	g.conn.set(ConnConnecting)
+	for attempt := uint(0); ; attempt++ {
+		// the event loop may wait here for long, it is alive as long as it retries
+		g.probe.beat()
+		err := g.connect(ctx)
+		if err == nil {
+			g.conn.set(ConnConnected)
+			g.Logger.Infof("connected to axiom after %d retries", attempt)
+			return nil
+		}
+		if errors.Is(err, ErrNetworkMismatch) {
+			g.Logger.Errorf("connect axiom error: %s, stop guardian", err)
+			g.conn.set(ConnDisconnected)
+			g.cancel()
+			return err
+		}
+
+		delay := jitterBackoff(attempt, g.Config.Reconnect.MinBackoff, g.Config.Reconnect.MaxBackoff)
+		g.Logger.Warnf("connect axiom error: %s, retry in %s", err, delay)
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			g.conn.set(ConnDisconnected)
+			return fmt.Errorf("connect axiom: %w, last error: %s", ctx.Err(), err)
+		}
+	}
+}
+
+// connect replaces the client with a new one from the client factory if it is on the expected network,
+// then catches up the logs missed
+func (g *Guardian) connect(ctx context.Context) error {
+	client, err := g.clientFactory(ctx)
+	if err != nil {
+		return err
+	}
+	// never act on another network, the client is dropped and dialed again later
+	if err := verifyNetwork(ctx, client, g.Config.Network); err != nil {
+		if closer, ok := client.(interface{ Close() }); ok {
+			closer.Close()
+		}
+		return err
+	}
+
+	g.clientLock.Lock()
+	old := g.Client
+	g.Client = client
+	g.clientLock.Unlock()
+	if closer, ok := old.(interface{ Close() }); ok && old != client {
+		closer.Close()
+	}
+
+	if err := g.fetchHistoryLog(); err != nil {
+		return err
+	}
+
+	return g.subscribeLog()
+}
+
+// jitterBackoff returns the delay before the retry attempt, doubled from min on every attempt up to max,
+// a random jitter of up to half of it keeps clients from retrying in lockstep
+func jitterBackoff(attempt uint, min, max time.Duration) time.Duration {
+	delay := min
+	for i := uint(0); i < attempt && delay < max; i++ {
+		delay *= 2
+	}
+	if delay > max {
+		delay = max
+	}
+	if delay <= 0 {
+		return 0
+	}
+
+	half := delay / 2
+	return half + time.Duration(rand.Int63n(int64(delay-half)+1))
+}
@@ -0,0 +1,157 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/axiomesh/guardian/repo"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJitterBackoff(t *testing.T) {
+	for attempt := uint(0); attempt < 100; attempt++ {
+		delay := jitterBackoff(attempt, time.Second, time.Minute)
+		expected := time.Second << attempt
+		if attempt > 5 {
+			expected = time.Minute
+		}
+		assert.GreaterOrEqual(t, delay, expected/2)
+		assert.LessOrEqual(t, delay, expected)
+	}
+}
+
+func TestReconnect(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var dials int32
+	failures := int32(3)
+	factory := func(ctx context.Context) (Client, error) {
+		if atomic.AddInt32(&dials, 1) > 1 && atomic.AddInt32(&failures, -1) >= 0 {
+			return nil, errors.New("connection refused")
+		}
+		return &MockClient{}, nil
+	}
+
+	c := repo.DefaultConfig(t.TempDir())
+	c.Reconnect.MinBackoff = 10 * time.Millisecond
+	c.Reconnect.MaxBackoff = 20 * time.Millisecond
+	guardian, err := NewGuardian(ctx, c, factory)
+	assert.Nil(t, err)
+	assert.Equal(t, ConnConnected, guardian.ConnState())
+	first := guardian.getClient()
+
+	reconnected := make(chan error, 1)
+	go func() {
+		reconnected <- guardian.reconnect(guardian.Ctx)
+	}()
+
+	waitCtx, waitCancel := context.WithTimeout(ctx, 5*time.Second)
+	defer waitCancel()
+	assert.Eventually(t, func() bool { return guardian.ConnState() == ConnConnecting }, time.Second, time.Millisecond)
+	assert.Nil(t, guardian.WaitConnected(waitCtx))
+	assert.Nil(t, <-reconnected)
+	assert.Equal(t, int32(5), atomic.LoadInt32(&dials))
+	assert.NotSame(t, first, guardian.getClient())
+
+	// never gives up until stopped
+	atomic.StoreInt32(&failures, 1000)
+	go func() {
+		reconnected <- guardian.reconnect(guardian.Ctx)
+	}()
+	time.Sleep(100 * time.Millisecond)
+	assert.Equal(t, ConnConnecting, guardian.ConnState())
+	guardian.cancel()
+	assert.ErrorIs(t, <-reconnected, context.Canceled)
+	assert.Equal(t, ConnDisconnected, guardian.ConnState())
+}
+
+func TestSupervisedColdStart(t *testing.T) {
+	c := repo.DefaultConfig(t.TempDir())
+	c.AxiomPath = t.TempDir()
+	c.Restart.Mode = repo.RestartModeSupervisor
+	c.Restart.Supervisor.StopTimeout = time.Second
+	c.Reconnect.MinBackoff = 10 * time.Millisecond
+	c.Reconnect.MaxBackoff = 20 * time.Millisecond
+	marker := filepath.Join(c.AxiomPath, "started")
+	assert.Nil(t, os.WriteFile(filepath.Join(c.AxiomPath, "axiom"), []byte("#!/bin/sh\ntouch "+marker+"\nwhile true; do sleep 0.05; done\n"), 0755))
+
+	// the rpc is only up once the supervised axiom runs
+	var dials int32
+	factory := func(ctx context.Context) (Client, error) {
+		atomic.AddInt32(&dials, 1)
+		if _, err := os.Stat(marker); err != nil {
+			return nil, errors.New("connection refused")
+		}
+		return &MockClient{}, nil
+	}
+
+	guardian, err := NewGuardian(context.Background(), c, factory)
+	assert.Nil(t, err)
+	assert.Zero(t, atomic.LoadInt32(&dials))
+	assert.Equal(t, ConnDisconnected, guardian.ConnState())
+	assert.Equal(t, ErrNotConnected.Error(), guardian.Status(context.Background()).RPC.Error)
+
+	assert.Nil(t, guardian.Start())
+	defer guardian.Stop()
+	assert.Equal(t, ConnConnected, guardian.ConnState())
+	assert.NotNil(t, guardian.getClient())
+}
+
+func TestReconnectNetworkMismatch(t *testing.T) {
+	var dials int32
+	factory := func(ctx context.Context) (Client, error) {
+		atomic.AddInt32(&dials, 1)
+		return &MockClient{}, nil
+	}
+
+	c := repo.DefaultConfig(t.TempDir())
+	c.Reconnect.MinBackoff = 10 * time.Millisecond
+	c.Reconnect.MaxBackoff = 20 * time.Millisecond
+	guardian, err := NewGuardian(context.Background(), c, factory)
+	assert.Nil(t, err)
+
+	// axiom comes back on another chain
+	guardian.Config.Network.ChainID = 1
+	assert.ErrorIs(t, guardian.reconnect(guardian.Ctx), ErrNetworkMismatch)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&dials))
+	assert.Equal(t, ConnDisconnected, guardian.ConnState())
+	assert.ErrorIs(t, guardian.Ctx.Err(), context.Canceled)
+	assert.False(t, guardian.Readiness().OK)
+}
+
+func TestVerifyUpgradeRPCNeverBack(t *testing.T) {
+	var dials int32
+	factory := func(ctx context.Context) (Client, error) {
+		if atomic.AddInt32(&dials, 1) > 1 {
+			return nil, errors.New("connection refused")
+		}
+		return &MockClient{}, nil
+	}
+
+	c := repo.DefaultConfig(t.TempDir())
+	c.Reconnect.MinBackoff = 10 * time.Millisecond
+	c.Reconnect.MaxBackoff = 20 * time.Millisecond
+	c.HealthCheck.Timeout = 200 * time.Millisecond
+	guardian, err := NewGuardian(context.Background(), c, factory)
+	assert.Nil(t, err)
+	defer guardian.cancel()
+	guardian.nextUpgradeVersion = "1.1.0"
+
+	// the log listener found the connection lost first, it retries as long as guardian runs
+	go func() {
+		_ = guardian.reconnect(guardian.Ctx)
+	}()
+	assert.Eventually(t, func() bool { return guardian.ConnState() == ConnConnecting }, time.Second, time.Millisecond)
+
+	start := time.Now()
+	err = guardian.verifyUpgrade()
+	assert.ErrorContains(t, err, "rpc is not available")
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Less(t, time.Since(start), 2*time.Second)
+}
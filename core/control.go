@@ -0,0 +1,377 @@
+package core
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+// ControlServer serves the admin api of the running guardian over a unix socket, and a loopback tcp address if set.
+// Every request must carry the admin token as a bearer token.
+type ControlServer struct {
+	guardian *Guardian
+	socket   string
+	token    string
+	server   *http.Server
+	// tcp serves the same api on the tcp address, nil if not set
+	tcp    *httpListener
+	logger logrus.FieldLogger
+}
+
+func NewControlServer(guardian *Guardian, socket, addr, token string, logger logrus.FieldLogger) *ControlServer {
+	s := &ControlServer{
+		guardian: guardian,
+		socket:   socket,
+		token:    token,
+		logger:   logger,
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/status", s.handleStatus)
+	mux.HandleFunc("/rescan", s.handleRescan)
+	mux.HandleFunc("/upgrade/queue", s.handleQueue)
+	mux.HandleFunc("/upgrade/pending", s.handlePending)
+	mux.HandleFunc("/upgrade/approve", s.handleDecision(guardian.Approve))
+	mux.HandleFunc("/upgrade/reject", s.handleDecision(guardian.Reject))
+	mux.HandleFunc("/upgrade/abort", s.handleDecision(guardian.AbortUpgrade))
+	mux.HandleFunc("/upgrade/pause", s.handlePause(true))
+	mux.HandleFunc("/upgrade/resume", s.handlePause(false))
+	mux.HandleFunc("/policy/reload", s.handlePolicyReload)
+	s.server = &http.Server{Handler: s.authenticate(mux)}
+	if addr != "" {
+		tcp := newHTTPListener("control", addr, s.authenticate(mux), logger)
+		s.tcp = &tcp
+	}
+
+	return s
+}
+
+func (s *ControlServer) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(s.token)) != 1 {
+			writeError(w, http.StatusUnauthorized, errors.New("invalid admin token"))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// checkLoopback refuses an address reachable from other hosts
+func checkLoopback(addr string) error {
+	if addr == "" {
+		return nil
+	}
+
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return fmt.Errorf("invalid address %s: %w", addr, err)
+	}
+	if host == "localhost" {
+		return nil
+	}
+	if ip := net.ParseIP(host); ip == nil || !ip.IsLoopback() {
+		return fmt.Errorf("address %s is not a loopback address", addr)
+	}
+	return nil
+}
+
+func (s *ControlServer) Start() error {
+	// a socket left by a crashed guardian blocks listening
+	if err := os.Remove(s.socket); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	listener, err := net.Listen("unix", s.socket)
+	if err != nil {
+		return fmt.Errorf("listen control socket: %w", err)
+	}
+	// only the user running guardian can control it
+	if err := os.Chmod(s.socket, 0600); err != nil {
+		listener.Close()
+		return err
+	}
+
+	if s.tcp != nil {
+		if err := s.tcp.Start(); err != nil {
+			listener.Close()
+			return err
+		}
+	}
+
+	go func() {
+		if err := s.server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			s.logger.Errorf("control server error: %s", err)
+		}
+	}()
+	s.logger.Infof("control server listens on %s", s.socket)
+
+	return nil
+}
+
+// Addr returns the tcp listening address after Start, empty if not listening on tcp
+func (s *ControlServer) Addr() string {
+	if s.tcp == nil {
+		return ""
+	}
+	return s.tcp.Addr()
+}
+
+func (s *ControlServer) Stop() error {
+	err := s.server.Close()
+	_ = os.Remove(s.socket)
+	if s.tcp != nil {
+		if tcpErr := s.tcp.Stop(); err == nil {
+			err = tcpErr
+		}
+	}
+	return err
+}
+
+func (s *ControlServer) handleStatus(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("method %s not allowed", r.Method))
+		return
+	}
+
+	writeJSON(w, http.StatusOK, s.guardian.Status(r.Context()))
+}
+
+func (s *ControlServer) handleRescan(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("method %s not allowed", r.Method))
+		return
+	}
+
+	fromBlock, err := strconv.ParseUint(r.URL.Query().Get("from"), 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("invalid from block: %w", err))
+		return
+	}
+
+	found, err := s.guardian.Rescan(r.Context(), fromBlock)
+	if err != nil {
+		writeError(w, http.StatusBadGateway, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, &RescanResult{FromBlock: fromBlock, Logs: found})
+}
+
+func (s *ControlServer) handlePause(pause bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("method %s not allowed", r.Method))
+			return
+		}
+
+		if pause {
+			s.guardian.PauseUpgrades()
+		} else {
+			s.guardian.ResumeUpgrades()
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func (s *ControlServer) handlePending(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("method %s not allowed", r.Method))
+		return
+	}
+
+	writeJSON(w, http.StatusOK, s.guardian.PendingApproval())
+}
+
+func (s *ControlServer) handleQueue(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("method %s not allowed", r.Method))
+		return
+	}
+
+	writeJSON(w, http.StatusOK, s.guardian.WorkerStatus())
+}
+
+func (s *ControlServer) handleDecision(decide func(proposalID uint64) error) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("method %s not allowed", r.Method))
+			return
+		}
+
+		proposalID, err := strconv.ParseUint(r.URL.Query().Get("id"), 10, 64)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("invalid proposal id: %w", err))
+			return
+		}
+
+		if err := decide(proposalID); err != nil {
+			status := http.StatusInternalServerError
+			if errors.Is(err, ErrNotAwaitingApproval) || errors.Is(err, ErrNotAbortable) {
+				status = http.StatusConflict
+			}
+			writeError(w, status, err)
+			return
+		}
+		s.logger.Infof("%s of proposal %d received", strings.TrimPrefix(r.URL.Path, "/upgrade/"), proposalID)
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func (s *ControlServer) handlePolicyReload(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("method %s not allowed", r.Method))
+		return
+	}
+
+	if err := s.guardian.ReloadPolicy(); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"message": err.Error()})
+}
+
+// RescanResult is the reply of a rescan
+type RescanResult struct {
+	FromBlock uint64
+	Logs      int
+}
+
+// ControlClient sends operator commands to the control server of a running guardian
+type ControlClient struct {
+	client *http.Client
+	token  string
+}
+
+func NewControlClient(socket, token string) *ControlClient {
+	return &ControlClient{client: newUnixHTTPClient(socket), token: token}
+}
+
+// Status returns what the guardian is doing
+func (c *ControlClient) Status(ctx context.Context) (*AdminStatus, error) {
+	status := &AdminStatus{}
+	if err := c.do(ctx, http.MethodGet, "/status", status); err != nil {
+		return nil, err
+	}
+	return status, nil
+}
+
+// Rescan makes the guardian handle the proposal logs from fromBlock again
+func (c *ControlClient) Rescan(ctx context.Context, fromBlock uint64) (*RescanResult, error) {
+	result := &RescanResult{}
+	if err := c.do(ctx, http.MethodPost, fmt.Sprintf("/rescan?from=%d", fromBlock), result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// Abort stops the upgrade by proposal before restart
+func (c *ControlClient) Abort(ctx context.Context, proposalID uint64) error {
+	return c.do(ctx, http.MethodPost, fmt.Sprintf("/upgrade/abort?id=%d", proposalID), nil)
+}
+
+func (c *ControlClient) Pause(ctx context.Context) error {
+	return c.do(ctx, http.MethodPost, "/upgrade/pause", nil)
+}
+
+func (c *ControlClient) Resume(ctx context.Context) error {
+	return c.do(ctx, http.MethodPost, "/upgrade/resume", nil)
+}
+
+func (c *ControlClient) Approve(ctx context.Context, proposalID uint64) error {
+	return c.do(ctx, http.MethodPost, fmt.Sprintf("/upgrade/approve?id=%d", proposalID), nil)
+}
+
+func (c *ControlClient) Reject(ctx context.Context, proposalID uint64) error {
+	return c.do(ctx, http.MethodPost, fmt.Sprintf("/upgrade/reject?id=%d", proposalID), nil)
+}
+
+// ReloadPolicy makes the running guardian read the policy from its config file again
+func (c *ControlClient) ReloadPolicy(ctx context.Context) error {
+	return c.do(ctx, http.MethodPost, "/policy/reload", nil)
+}
+
+// Queue returns the state of the upgrade worker and its queue
+func (c *ControlClient) Queue(ctx context.Context) (*WorkerStatus, error) {
+	status := &WorkerStatus{}
+	if err := c.do(ctx, http.MethodGet, "/upgrade/queue", status); err != nil {
+		return nil, err
+	}
+	return status, nil
+}
+
+// Pending returns the upgrade awaiting approval, nil if none
+func (c *ControlClient) Pending(ctx context.Context) (*PendingApproval, error) {
+	var pending *PendingApproval
+	if err := c.do(ctx, http.MethodGet, "/upgrade/pending", &pending); err != nil {
+		return nil, err
+	}
+	return pending, nil
+}
+
+func (c *ControlClient) do(ctx context.Context, method, path string, result any) error {
+	req, err := http.NewRequestWithContext(ctx, method, "http://guardian"+path, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+c.token)
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("connect guardian: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		var apiErr struct {
+			Message string `json:"message"`
+		}
+		if json.Unmarshal(body, &apiErr) == nil && apiErr.Message != "" {
+			return errors.New(apiErr.Message)
+		}
+		return fmt.Errorf("status code: %v, body: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	if result == nil {
+		return nil
+	}
+	return json.Unmarshal(body, result)
+}
+
+// newUnixHTTPClient returns a http client sends every request to the unix socket
+func newUnixHTTPClient(socket string) *http.Client {
+	return &http.Client{
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				var d net.Dialer
+				return d.DialContext(ctx, "unix", socket)
+			},
+		},
+	}
+}
@@ -0,0 +1,111 @@
+package core
+
+import (
+	"encoding/json"
+	"expvar"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/pprof"
+	"os"
+	"path/filepath"
+	runtimepprof "runtime/pprof"
+	"time"
+
+	"github.com/axiomesh/guardian/repo"
+	"github.com/sirupsen/logrus"
+)
+
+// StateSnapshot is the state of guardian written for troubleshooting, it never blocks on axiom
+type StateSnapshot struct {
+	Time            int64
+	ConnState       string
+	Checkpoint      uint64
+	Worker          *WorkerStatus
+	PendingApproval *PendingApproval
+	// Upgrade is the upgrade in progress as the worker sees it
+	Upgrade *UpgradeRecord
+	// PersistedUpgrade is the upgrade in progress in db, the next start resumes it
+	PersistedUpgrade *UpgradeRecord
+	PersistedError   string `json:",omitempty"`
+}
+
+func (g *Guardian) stateSnapshot() *StateSnapshot {
+	snapshot := &StateSnapshot{
+		Time:            time.Now().Unix(),
+		ConnState:       g.ConnState().String(),
+		Checkpoint:      g.Checkpoint(),
+		Worker:          g.WorkerStatus(),
+		PendingApproval: g.PendingApproval(),
+		Upgrade:         g.ActiveUpgrade(),
+	}
+	if upgrade, err := g.CurrentUpgrade(); err != nil {
+		snapshot.PersistedError = err.Error()
+	} else {
+		snapshot.PersistedUpgrade = upgrade
+	}
+
+	return snapshot
+}
+
+// WriteStateSnapshot writes the state and the stacks of all goroutines to a new file in the logs directory
+// and returns its path
+func (g *Guardian) WriteStateSnapshot() (string, error) {
+	dir := filepath.Join(g.Config.RepoRoot, repo.LogsDirName)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+
+	path := filepath.Join(dir, fmt.Sprintf("state-%s.txt", time.Now().Format("20060102-150405.000")))
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	if err := g.dumpState(f); err != nil {
+		return "", err
+	}
+
+	return path, f.Close()
+}
+
+func (g *Guardian) dumpState(w io.Writer) error {
+	fmt.Fprintln(w, "state:")
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(g.stateSnapshot()); err != nil {
+		return err
+	}
+
+	fmt.Fprintln(w, "\ngoroutines:")
+	return writeGoroutines(w)
+}
+
+func writeGoroutines(w io.Writer) error {
+	return runtimepprof.Lookup("goroutine").WriteTo(w, 2)
+}
+
+// DebugServer serves pprof, expvar, the goroutine dump and the state snapshot, it should only listen on loopback
+type DebugServer struct {
+	httpListener
+}
+
+func NewDebugServer(guardian *Guardian, addr string, logger logrus.FieldLogger) *DebugServer {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	mux.Handle("/debug/vars", expvar.Handler())
+	mux.HandleFunc("/debug/goroutines", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		_ = writeGoroutines(w)
+	})
+	mux.HandleFunc("/debug/state", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, guardian.stateSnapshot())
+	})
+
+	return &DebugServer{newHTTPListener("debug", addr, mux, logger)}
+}
@@ -0,0 +1,61 @@
+package core
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/axiomesh/guardian/repo"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDebugServer(t *testing.T) {
+	c := repo.DefaultConfig(t.TempDir())
+	c.AxiomPath = t.TempDir()
+	c.Debug.Enable = true
+	c.Debug.ListenAddr = "127.0.0.1:0"
+	guardian, err := NewGuardian(context.Background(), c, StaticClientFactory(&MockClient{}))
+	assert.Nil(t, err)
+	assert.Nil(t, guardian.Start())
+	defer guardian.Stop()
+
+	get := func(path string) string {
+		resp, err := http.Get("http://" + guardian.debugServer.Addr() + path)
+		assert.Nil(t, err)
+		defer resp.Body.Close()
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		body, err := io.ReadAll(resp.Body)
+		assert.Nil(t, err)
+		return string(body)
+	}
+	assert.Contains(t, get("/debug/pprof/"), "goroutine")
+	assert.Contains(t, get("/debug/vars"), "memstats")
+	assert.Contains(t, get("/debug/goroutines"), "listenEvents")
+	assert.Contains(t, get("/debug/state"), `"ConnState":"connected"`)
+
+	path, err := guardian.WriteStateSnapshot()
+	assert.Nil(t, err)
+	assert.Equal(t, filepath.Join(c.RepoRoot, repo.LogsDirName), filepath.Dir(path))
+	data, err := os.ReadFile(path)
+	assert.Nil(t, err)
+	assert.Contains(t, string(data), `"Checkpoint"`)
+	assert.Contains(t, string(data), "goroutines:")
+	assert.Contains(t, string(data), "upgradeWorker")
+}
+
+func TestDebugLoopbackOnly(t *testing.T) {
+	for addr, msg := range map[string]string{
+		"0.0.0.0:9403": "not a loopback address",
+		":9403":        "not a loopback address",
+		"":             "listen address is empty",
+	} {
+		c := repo.DefaultConfig(t.TempDir())
+		c.Debug.Enable = true
+		c.Debug.ListenAddr = addr
+		_, err := NewGuardian(context.Background(), c, StaticClientFactory(&MockClient{}))
+		assert.ErrorContains(t, err, msg, addr)
+	}
+}
@@ -0,0 +1,145 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+// DeltaArtifact is a binary patch of the axiom binary against an installed base version
+type DeltaArtifact struct {
+	// BaseVersion is the axiom version the patch applies to
+	BaseVersion string
+
+	DownloadUrls []string
+
+	// CheckHash is the sha256 of the patch file
+	CheckHash string
+
+	// TargetHash is the sha256 of the axiom binary after patching
+	TargetHash string
+}
+
+// downloadDelta downloads the patch, applies it to the installed axiom binary
+// and returns a new release directory contains the patched binary.
+func (g *Guardian) downloadDelta(delta *DeltaArtifact, currentVersion *Version) (string, error) {
+	baseVersion, err := ParseVersion(delta.BaseVersion)
+	if err != nil {
+		return "", fmt.Errorf("parse delta base version: %w", err)
+	}
+	if baseVersion.Compare(currentVersion) != 0 {
+		return "", fmt.Errorf("delta base version %s not match current version %s", baseVersion, currentVersion)
+	}
+
+	patchPath, err := g.fetch(delta.DownloadUrls, delta.CheckHash)
+	if err != nil {
+		return "", fmt.Errorf("download delta: %w", err)
+	}
+
+	patch, err := os.ReadFile(patchPath)
+	if err != nil {
+		return "", err
+	}
+
+	old, err := os.ReadFile(g.installedBinaryPath())
+	if err != nil {
+		return "", err
+	}
+
+	newData, err := bspatch(old, patch)
+	if err != nil {
+		return "", fmt.Errorf("apply delta: %w", err)
+	}
+
+	dstPath, err := g.newReleaseDir()
+	if err != nil {
+		return "", err
+	}
+
+	if err := g.buildDeltaRelease(dstPath, newData, delta.TargetHash); err != nil {
+		if err := os.RemoveAll(dstPath); err != nil {
+			g.Logger.Errorf("remove invalid release %s error: %s", dstPath, err)
+		}
+		return "", err
+	}
+
+	g.Logger.Infof("delta upgrade from %s applied, patched binary hash check passed", delta.BaseVersion)
+
+	return dstPath, nil
+}
+
+// buildDeltaRelease writes the patched binary into dstPath and copies the other files
+// of the installed release, the result is checked against the installed manifest with the target digest.
+func (g *Guardian) buildDeltaRelease(dstPath string, binary []byte, targetHash string) error {
+	baseDir := filepath.Dir(g.installedBinaryPath())
+	base, err := LoadManifest(baseDir)
+	if err != nil {
+		return fmt.Errorf("load installed release manifest: %w", err)
+	}
+	entries, err := base.entries()
+	if err != nil {
+		return fmt.Errorf("installed release manifest: %w", err)
+	}
+
+	manifest := &Manifest{Files: make([]ManifestFile, 0, len(entries))}
+	for p, f := range entries {
+		dst := filepath.Join(dstPath, filepath.FromSlash(p))
+		if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+			return err
+		}
+
+		if p == "axiom" {
+			if err := os.WriteFile(dst, binary, 0755); err != nil {
+				return err
+			}
+			if !g.checkFileHash(dst, targetHash) {
+				return fmt.Errorf("patched binary hash check failed")
+			}
+			f.Digest = targetHash
+		} else if err := copyFile(filepath.Join(baseDir, filepath.FromSlash(p)), dst); err != nil {
+			return err
+		}
+
+		// the manifest check compares the mode, which the umask may have changed
+		mode, err := strconv.ParseUint(f.Mode, 8, 32)
+		if err != nil {
+			return fmt.Errorf("manifest file %s has invalid mode %q", f.Path, f.Mode)
+		}
+		if err := os.Chmod(dst, fs.FileMode(mode).Perm()); err != nil {
+			return err
+		}
+
+		manifest.Files = append(manifest.Files, f)
+	}
+
+	data, err := json.Marshal(manifest)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(dstPath, ManifestFileName), data, 0644); err != nil {
+		return err
+	}
+
+	if err := manifest.Validate(dstPath); err != nil {
+		return fmt.Errorf("validate delta release: %w", err)
+	}
+
+	return nil
+}
+
+func copyFile(src, dst string) error {
+	info, err := os.Stat(src)
+	if err != nil {
+		return err
+	}
+
+	data, err := os.ReadFile(src)
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(dst, data, info.Mode().Perm())
+}
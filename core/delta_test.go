@@ -0,0 +1,140 @@
+package core
+
+import (
+	"context"
+	"encoding/binary"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/axiomesh/guardian/repo"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBspatch(t *testing.T) {
+	old, err := os.ReadFile("testdata/axiom-1.0.0")
+	assert.Nil(t, err)
+	expected, err := os.ReadFile("testdata/axiom-1.0.1")
+	assert.Nil(t, err)
+	patch, err := os.ReadFile("testdata/axiom-1.0.0-1.0.1.bsdiff")
+	assert.Nil(t, err)
+
+	newData, err := bspatch(old, patch)
+	assert.Nil(t, err)
+	assert.Equal(t, expected, newData)
+
+	_, err = bspatch(old, patch[:40])
+	assert.ErrorIs(t, err, ErrCorruptPatch)
+
+	_, err = bspatch(old, []byte("not a patch"))
+	assert.ErrorIs(t, err, ErrCorruptPatch)
+
+	header := func(ctrlLen, diffLen, newSize uint64) []byte {
+		h := []byte(bsdiffMagic)
+		for _, v := range []uint64{ctrlLen, diffLen, newSize} {
+			buf := make([]byte, 8)
+			binary.LittleEndian.PutUint64(buf, v)
+			h = append(h, buf...)
+		}
+		return append(h, make([]byte, 64)...)
+	}
+
+	// the declared size is refused before it is allocated
+	_, err = bspatch(old, header(0, 0, 1<<62))
+	assert.ErrorContains(t, err, "exceeds limit")
+
+	// 32+ctrlLen+diffLen overflows int64
+	_, err = bspatch(old, header(1<<62, 1<<62, 10))
+	assert.ErrorIs(t, err, ErrCorruptPatch)
+	_, err = bspatch(old, header(32, 33, 10))
+	assert.ErrorContains(t, err, "bad header")
+}
+
+func TestDownloadDelta(t *testing.T) {
+	axiomPath := t.TempDir()
+	old, err := os.ReadFile("testdata/axiom-1.0.0")
+	assert.Nil(t, err)
+	assert.Nil(t, os.WriteFile(filepath.Join(axiomPath, "axiom"), old, 0755))
+	assert.Nil(t, os.WriteFile(filepath.Join(axiomPath, "version.sh"), []byte("echo 'Axiom version: 1.0.0'"), 0755))
+	assert.Nil(t, os.MkdirAll(filepath.Join(axiomPath, "config"), 0755))
+	assert.Nil(t, os.WriteFile(filepath.Join(axiomPath, "config", "axiom.toml"), []byte("[port]"), 0644))
+	manifest := &Manifest{}
+	for name, role := range map[string]FileRole{"axiom": RoleBinary, "version.sh": RoleScript, "config/axiom.toml": RoleConfig} {
+		p := filepath.Join(axiomPath, filepath.FromSlash(name))
+		assert.Nil(t, os.Chmod(p, 0755))
+		digest, err := fileSHA256(p)
+		assert.Nil(t, err)
+		manifest.Files = append(manifest.Files, ManifestFile{Path: name, Digest: digest, Mode: "0755", Role: role})
+	}
+	saveManifest(t, axiomPath, manifest)
+
+	server := httptest.NewServer(http.FileServer(http.Dir("testdata")))
+	defer server.Close()
+
+	patchHash, err := fileSHA256("testdata/axiom-1.0.0-1.0.1.bsdiff")
+	assert.Nil(t, err)
+	targetHash, err := fileSHA256("testdata/axiom-1.0.1")
+	assert.Nil(t, err)
+
+	c := repo.DefaultConfig(t.TempDir())
+	c.AxiomPath = axiomPath
+	g, err := NewGuardian(context.Background(), c, StaticClientFactory(&MockClient{}))
+	assert.Nil(t, err)
+
+	delta := &DeltaArtifact{
+		BaseVersion:  "1.0.0",
+		DownloadUrls: []string{server.URL + "/axiom-1.0.0-1.0.1.bsdiff"},
+		CheckHash:    patchHash,
+		TargetHash:   targetHash,
+	}
+
+	t.Run("apply", func(t *testing.T) {
+		releasePath, err := g.downloadDelta(delta, &Version{Major: 1})
+		assert.Nil(t, err)
+
+		sum, err := fileSHA256(filepath.Join(releasePath, "axiom"))
+		assert.Nil(t, err)
+		assert.Equal(t, targetHash, sum)
+		assert.FileExists(t, filepath.Join(releasePath, "version.sh"))
+		assert.FileExists(t, filepath.Join(releasePath, "config", "axiom.toml"))
+		assert.Nil(t, g.verifyRelease(releasePath))
+	})
+
+	t.Run("installed release modified", func(t *testing.T) {
+		configPath := filepath.Join(axiomPath, "config", "axiom.toml")
+		assert.Nil(t, os.WriteFile(configPath, []byte("[port]\njsonrpc = 8881"), 0755))
+		defer func() {
+			assert.Nil(t, os.WriteFile(configPath, []byte("[port]"), 0755))
+		}()
+
+		_, err := g.downloadDelta(delta, &Version{Major: 1})
+		assert.ErrorContains(t, err, "validate delta release")
+	})
+
+	t.Run("installed release without manifest", func(t *testing.T) {
+		manifestPath := filepath.Join(axiomPath, ManifestFileName)
+		data, err := os.ReadFile(manifestPath)
+		assert.Nil(t, err)
+		assert.Nil(t, os.Remove(manifestPath))
+		defer func() {
+			assert.Nil(t, os.WriteFile(manifestPath, data, 0644))
+		}()
+
+		_, err = g.downloadDelta(delta, &Version{Major: 1})
+		assert.ErrorContains(t, err, "load installed release manifest")
+	})
+
+	t.Run("base mismatch", func(t *testing.T) {
+		_, err := g.downloadDelta(delta, &Version{Minor: 9})
+		assert.ErrorContains(t, err, "base version")
+	})
+
+	t.Run("target hash mismatch", func(t *testing.T) {
+		bad := *delta
+		bad.TargetHash = patchHash
+		_, err := g.downloadDelta(&bad, &Version{Major: 1})
+		assert.ErrorContains(t, err, "patched binary hash check failed")
+	})
+}
@@ -0,0 +1,108 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"time"
+)
+
+const dryRunKeyPrefix = "dryRun-"
+
+// DryRunRecord is stored instead of restarting axiom in dry run mode
+type DryRunRecord struct {
+	ProposalID  uint64
+	FromVersion string
+	ToVersion   string
+	ReleasePath string
+	// Actions are the steps a real upgrade would take, in order
+	Actions []string
+	Time    int64
+}
+
+// dryRunState replaces the persisted upgrade progress in dry run mode, so the real state is never written
+type dryRunState struct {
+	lastProposalID uint64
+	applied        bool
+	// version is the version axiom would run after the simulated upgrades
+	version *Version
+	// checkpoint is kept in memory, a later real run must see the logs simulated
+	checkpoint uint64
+}
+
+// simulateRestart logs and records what the upgrade to the staged release in releasePath would do
+func (g *Guardian) simulateRestart(proposal *NodeProposal, currentVersion *Version, releasePath string) error {
+	version, err := ParseVersion(g.nextUpgradeVersion)
+	if err != nil {
+		return err
+	}
+
+	// the hooks before restart are skipped too, they are listed in order of the phases
+	actions := append(g.hookActions(HookPreDownload), g.hookActions(HookPostVerify)...)
+	if g.Config.Approval.Enable {
+		actions = append(actions, "await operator approval")
+	}
+	if g.snapshots != nil {
+		actions = append(actions, fmt.Sprintf("snapshot data directory %s", g.Config.Snapshot.DataPath))
+	}
+	actions = append(actions, g.hookActions(HookPreRestart)...)
+
+	binaryPath := filepath.Join(releasePath, "axiom")
+	if g.Config.Release.Managed {
+		versionPath := filepath.Join(g.Config.AxiomPath, ReleasesDirName, version.String())
+		actions = append(actions,
+			fmt.Sprintf("install release %s as %s", releasePath, versionPath),
+			fmt.Sprintf("switch %s to %s", filepath.Join(g.Config.AxiomPath, CurrentLinkName), versionPath))
+		binaryPath = liveBinaryPath(g.Config)
+	}
+	actions = append(actions,
+		g.restarter.Describe(binaryPath),
+		fmt.Sprintf("check axiom runs version %s healthily", version))
+	actions = append(actions, g.hookActions(HookPostRestart)...)
+
+	record := &DryRunRecord{
+		ProposalID:  proposal.ID,
+		FromVersion: currentVersion.String(),
+		ToVersion:   version.String(),
+		ReleasePath: releasePath,
+		Actions:     actions,
+		Time:        time.Now().Unix(),
+	}
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	g.DB.Put([]byte(fmt.Sprintf("%s%d", dryRunKeyPrefix, proposal.ID)), data)
+
+	g.Logger.Infof("dry run: upgrade by proposal %d from version %s to %s would:", proposal.ID, currentVersion, version)
+	for i, action := range actions {
+		g.Logger.Infof("dry run: %d. %s", i+1, action)
+	}
+
+	g.dryRun.version = version
+	g.recordUpgradeProposal(proposal.ID)
+	return nil
+}
+
+func (g *Guardian) hookActions(phase HookPhase) []string {
+	var actions []string
+	for _, hook := range g.hooksOf(phase) {
+		actions = append(actions, fmt.Sprintf("run %s hook %s: %s", phase, hook.Name, hook.Command))
+	}
+	return actions
+}
+
+// getDryRunRecord returns the dry run record of proposal, nil if it is not simulated
+func (g *Guardian) getDryRunRecord(proposalID uint64) (*DryRunRecord, error) {
+	data := g.DB.Get([]byte(fmt.Sprintf("%s%d", dryRunKeyPrefix, proposalID)))
+	if data == nil {
+		return nil, nil
+	}
+
+	record := &DryRunRecord{}
+	if err := json.Unmarshal(data, record); err != nil {
+		return nil, err
+	}
+
+	return record, nil
+}
@@ -0,0 +1,78 @@
+package core
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/axiomesh/guardian/repo"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSimulateRestart(t *testing.T) {
+	c := repo.DefaultConfig(t.TempDir())
+	c.AxiomPath = t.TempDir()
+	c.DryRun = true
+	c.Release.Managed = true
+	c.Restart.Mode = repo.RestartModeSystemd
+	c.Hooks.PreDownload = []repo.Hook{{Name: "space", Command: "df.sh"}}
+	c.Hooks.PostVerify = []repo.Hook{{Name: "scan", Command: "scan.sh"}}
+	c.Hooks.PreRestart = []repo.Hook{{Name: "drain", Command: "drain.sh"}}
+	guardian, err := NewGuardian(context.Background(), c, StaticClientFactory(&MockClient{}))
+	assert.Nil(t, err)
+
+	releasePath, _ := writeRelease(t, map[string]string{"axiom": "axiom v1.1.0", "version.sh": "echo 'Axiom version: v1.1.0'"})
+	guardian.nextUpgradeVersion = "1.1.0"
+	proposal := &NodeProposal{BaseProposal: BaseProposal{ID: 2}, Version: "1.1.0"}
+	assert.Nil(t, guardian.simulateRestart(proposal, &Version{Major: 1}, releasePath))
+
+	record, err := guardian.getDryRunRecord(2)
+	assert.Nil(t, err)
+	assert.Equal(t, "1.0.0", record.FromVersion)
+	assert.Equal(t, "1.1.0", record.ToVersion)
+	assert.Equal(t, releasePath, record.ReleasePath)
+	assert.Equal(t, []string{
+		"run pre-download hook space: df.sh",
+		"run post-verify hook scan: scan.sh",
+		"run pre-restart hook drain: drain.sh",
+		"install release " + releasePath + " as " + filepath.Join(c.AxiomPath, ReleasesDirName, "1.1.0"),
+		"switch " + filepath.Join(c.AxiomPath, CurrentLinkName) + " to " + filepath.Join(c.AxiomPath, ReleasesDirName, "1.1.0"),
+		"exec: systemctl restart axiom.service",
+		"check axiom runs version 1.1.0 healthily",
+	}, record.Actions)
+
+	// nothing is switched or persisted
+	assert.NoDirExists(t, filepath.Join(c.AxiomPath, ReleasesDirName))
+	assert.NoFileExists(t, filepath.Join(c.AxiomPath, CurrentLinkName))
+	assert.Nil(t, guardian.DB.Get([]byte(nextUpgradeVersion)))
+	assert.Nil(t, guardian.DB.Get([]byte(lastUpgradeProposalKey)))
+
+	// the simulated proposal is not planned again
+	lastID, applied := guardian.getLastUpgradeProposal()
+	assert.True(t, applied)
+	assert.Equal(t, uint64(2), lastID)
+	guardian.addApprovedProposal(proposal)
+	guardian.addApprovedProposal(&NodeProposal{BaseProposal: BaseProposal{ID: 3}, Version: "1.2.0"})
+	path := guardian.planUpgradePath(guardian.dryRun.version)
+	assert.Equal(t, 1, len(path))
+	assert.Equal(t, uint64(3), path[0].ID)
+}
+
+func TestDryRunSkipsHooks(t *testing.T) {
+	c := repo.DefaultConfig(t.TempDir())
+	c.DryRun = true
+	marker := filepath.Join(t.TempDir(), "ran")
+	c.Hooks.PreDownload = []repo.Hook{{Name: "touch", Command: "touch " + marker}}
+	c.Hooks.PostVerify = []repo.Hook{{Name: "fail", Command: "exit 1"}}
+	guardian, err := NewGuardian(context.Background(), c, StaticClientFactory(&MockClient{}))
+	assert.Nil(t, err)
+
+	hookCtx := &HookContext{ProposalID: 2, CurrentVersion: "1.0.0"}
+	results, err := guardian.runHooks(HookPreDownload, hookCtx)
+	assert.Nil(t, err)
+	assert.Empty(t, results)
+	assert.NoFileExists(t, marker)
+
+	_, err = guardian.runHooks(HookPostVerify, hookCtx)
+	assert.Nil(t, err)
+}
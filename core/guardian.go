@@ -3,7 +3,6 @@ package core
 import (
 	"context"
 	"crypto/rand"
-	"crypto/sha256"
 	"encoding/binary"
 	"encoding/json"
 	"errors"
@@ -15,7 +14,7 @@ import (
 	"os/exec"
 	"path"
 	"path/filepath"
-	"strings"
+	"sync"
 	"time"
 
 	"github.com/Rican7/retry"
@@ -28,19 +27,29 @@ import (
 	"github.com/ethereum/go-ethereum"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/types"
-	"github.com/ethereum/go-ethereum/ethclient"
 	"github.com/sirupsen/logrus"
 )
 
 const (
 	LogChanMaxSize = 1000
 
-	nextFromBlockKey   = "nextFromBlock"
-	nextUpgradeVersion = "nextUpgradeVersion"
+	nextFromBlockKey       = "nextFromBlock"
+	nextUpgradeVersion     = "nextUpgradeVersion"
+	lastUpgradeProposalKey = "lastUpgradeProposal"
+	upgradesPausedKey      = "upgradesPaused"
+
+	approvedProposalKeyPrefix = "approvedProposal-"
+)
+
+var (
+	ErrDowngrade    = errors.New("release is not newer than the current version")
+	ErrIncompatible = errors.New("node is incompatible with the upgrade proposal")
 )
 
 type Guardian struct {
-	Ctx    context.Context
+	// Ctx is canceled by Stop
+	Ctx context.Context
+	// Client is replaced on reconnect, read it by getClient
 	Client Client
 	Logger *logrus.Logger
 	DB     storage.Storage
@@ -52,13 +61,73 @@ type Guardian struct {
 	Addresses []common.Address
 	Topics    [][]common.Hash
 
+	// checkpointLock guards the next from block in db
+	checkpointLock sync.Mutex
+	// detected are the proposals notified as detected
+	detectedLock sync.Mutex
+	detected     map[uint64]bool
+
 	LogChan             chan types.Log
 	LogSub              ethereum.Subscription
+	approvedProposals   []*NodeProposal
 	nextUpgradeProposal *NodeProposal
 	nextUpgradeVersion  string
+
+	restarter Restarter
+
+	// queue feeds approved proposals to the upgrade worker, the only one accessing the upgrade fields above
+	queue *upgradeQueue
+
+	// snapshots backs up the axiom data directory before restart if enabled
+	snapshots *SnapshotManager
+
+	// dryRun is set in dry run mode
+	dryRun *dryRunState
+
+	// approval holds the verified release until an operator decides if enabled
+	approval approvalGate
+	// active is the upgrade in progress
+	active activeUpgrade
+
+	// notifier posts upgrade events to the webhooks, its deliveries outlive the context until Stop gives up waiting
+	notifier     *Notifier
+	notifyCancel context.CancelFunc
+
+	// audit records finished upgrades if enabled, never in dry run mode
+	audit *AuditLog
+	// upgradeAudit is the audit entry of the upgrade in progress, only accessed by the upgrade worker
+	upgradeAudit *AuditEntry
+	// control serves operator commands on the control socket
+	control *ControlServer
+
+	metrics *guardianMetrics
+	// metricsServer serves metrics if enabled
+	metricsServer *MetricsServer
+
+	probe probeState
+	// probeServer serves the liveness and readiness probes if enabled
+	probeServer *ProbeServer
+	// debugServer serves pprof and runtime diagnostics if enabled
+	debugServer *DebugServer
+
+	// policy is replaced when reloaded
+	policyLock sync.RWMutex
+	policy     *Policy
+
+	clientFactory ClientFactory
+	// clientLock guards Client and LogSub
+	clientLock sync.RWMutex
+	// reconnecting is held by the reconnect in progress, waiters give up with their ctx
+	reconnecting chan struct{}
+	conn         *connection
+
+	cancel context.CancelFunc
+	// wg tracks the goroutines started by Start
+	wg       sync.WaitGroup
+	stopOnce sync.Once
 }
 
-func NewGuardian(ctx context.Context, config *repo.Config, client Client) (*Guardian, error) {
+func NewGuardian(ctx context.Context, config *repo.Config, clientFactory ClientFactory) (*Guardian, error) {
 	logger := log.New()
 	logger.SetLevel(log.ParseLevel(config.Log.Level))
 
@@ -84,15 +153,87 @@ func NewGuardian(ctx context.Context, config *repo.Config, client Client) (*Guar
 		topics = append(topics, dstTopic)
 	}
 
+	restarter, err := NewRestarter(config, logger.WithField("module", "restarter"))
+	if err != nil {
+		return nil, err
+	}
+
+	var snapshots *SnapshotManager
+	if config.Snapshot.Enable {
+		snapshots, err = NewSnapshotManager(config, logger.WithField("module", "snapshot"))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	policy, err := NewPolicy(config.Policy)
+	if err != nil {
+		return nil, err
+	}
+
+	var dryRun *dryRunState
+	if config.DryRun {
+		dryRun = &dryRunState{}
+	}
+
+	var audit *AuditLog
+	if config.Audit.Enable && dryRun == nil {
+		audit, err = OpenAuditLog(config.RepoRoot)
+		if err != nil {
+			return nil, err
+		}
+		if err := audit.Err(); err != nil {
+			logger.Errorf("audit log is not appended: %s, check it by audit verify", err)
+		}
+	}
+
+	// a supervised axiom is not running until Start spawns it, it is dialed there
+	var client Client
+	if _, supervised := restarter.(restarterLifecycle); !supervised || dryRun != nil {
+		client, err = clientFactory(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("connect axiom: %w", err)
+		}
+		if err := verifyNetwork(ctx, client, config.Network); err != nil {
+			return nil, err
+		}
+	}
+
+	adminToken, err := loadOrCreateAdminToken(config.RepoRoot)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkLoopback(config.Admin.ListenAddr); err != nil {
+		return nil, fmt.Errorf("admin: %w", err)
+	}
+	if config.Debug.Enable {
+		// unlike the admin address, empty does not mean off, it would listen on all interfaces
+		if config.Debug.ListenAddr == "" {
+			return nil, errors.New("debug: listen address is empty")
+		}
+		if err := checkLoopback(config.Debug.ListenAddr); err != nil {
+			return nil, fmt.Errorf("debug: %w", err)
+		}
+	}
+
 	// new leveldb
 	db, err := leveldb.New(filepath.Join(config.RepoRoot, "leveldb"))
 	if err != nil {
 		return nil, err
 	}
 
+	notifyCtx, notifyCancel := context.WithCancel(ctx)
+	notifier, err := NewNotifier(notifyCtx, config.Webhooks, logger.WithField("module", "webhook"))
+	if err != nil {
+		notifyCancel()
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+
 	logChan := make(chan types.Log, LogChanMaxSize)
 
-	return &Guardian{
+	g := &Guardian{
 		Ctx:       ctx,
 		Client:    client,
 		Logger:    logger,
@@ -103,30 +244,115 @@ func NewGuardian(ctx context.Context, config *repo.Config, client Client) (*Guar
 		Addresses: addresses,
 		Topics:    topics,
 		LogChan:   logChan,
-	}, nil
+		detected:  make(map[uint64]bool),
+
+		restarter: restarter,
+		queue:     newUpgradeQueue(len(db.Get([]byte(upgradesPausedKey))) != 0),
+		snapshots: snapshots,
+		dryRun:    dryRun,
+		audit:     audit,
+		policy:    policy,
+		cancel:    cancel,
+
+		notifier:     notifier,
+		notifyCancel: notifyCancel,
+
+		clientFactory: clientFactory,
+		conn:          newConnection(),
+		reconnecting:  make(chan struct{}, 1),
+	}
+	if client != nil {
+		g.conn.set(ConnConnected)
+	}
+	g.control = NewControlServer(g, filepath.Join(config.RepoRoot, repo.ControlSocketName), config.Admin.ListenAddr, adminToken, logger.WithField("module", "control"))
+	g.metrics = newGuardianMetrics(g)
+	if config.Metrics.Enable {
+		g.metricsServer = NewMetricsServer(config.Metrics.ListenAddr, g.metrics.registry, logger.WithField("module", "metrics"))
+	}
+	if config.Probe.Enable {
+		g.probeServer = NewProbeServer(g, config.Probe.ListenAddr, logger.WithField("module", "probe"))
+	}
+	if config.Debug.Enable {
+		g.debugServer = NewDebugServer(g, config.Debug.ListenAddr, logger.WithField("module", "debug"))
+	}
+
+	return g, nil
 }
 
 func (g *Guardian) Start() error {
-	if err := g.fetchHistoryLog(); err != nil {
+	if g.dryRun != nil {
+		g.Logger.Warn("dry run mode, axiom will not be restarted")
+	}
+
+	if err := g.control.Start(); err != nil {
 		return err
 	}
 
-	if err := g.subscribeLog(); err != nil {
+	if g.metricsServer != nil {
+		if err := g.metricsServer.Start(); err != nil {
+			return err
+		}
+	}
+
+	if g.probeServer != nil {
+		if err := g.probeServer.Start(); err != nil {
+			return err
+		}
+	}
+
+	if g.debugServer != nil {
+		if err := g.debugServer.Start(); err != nil {
+			return err
+		}
+	}
+
+	// axiom is left running as it is in dry run mode
+	if lifecycle, ok := g.restarter.(restarterLifecycle); ok && g.dryRun == nil {
+		if err := lifecycle.Start(); err != nil {
+			return err
+		}
+	}
+
+	// the proposals approved before the checkpoint and not applied yet are not fetched again
+	if err := g.restoreApprovedProposals(); err != nil {
 		return err
 	}
 
-	go g.listenEvents()
+	if g.getClient() == nil {
+		// the rpc of the axiom just spawned comes up after a while, reconnect also fetches the logs and subscribes
+		if err := g.reconnect(g.Ctx); err != nil {
+			return err
+		}
+	} else {
+		if err := g.fetchHistoryLog(); err != nil {
+			return err
+		}
+
+		if err := g.subscribeLog(); err != nil {
+			return err
+		}
+	}
 
-	go g.downloadAndRestart()
+	g.wg.Add(2)
+	go func() {
+		defer g.wg.Done()
+		g.listenEvents()
+	}()
+
+	go func() {
+		defer g.wg.Done()
+		g.upgradeWorker()
+	}()
 
 	return nil
 }
 
 func (g *Guardian) fetchHistoryLog() error {
+	g.probe.backfilled.Store(false)
 	fromBlock := g.getNewestFromBlock()
 
 	// TODO: to block sub from block should be less than 10000
-	logs, err := g.Client.FilterLogs(g.Ctx, ethereum.FilterQuery{
+	logs, err := g.getClient().FilterLogs(g.Ctx, ethereum.FilterQuery{
 		FromBlock: fromBlock,
 		ToBlock:   g.ToBlock,
 		Addresses: g.Addresses,
@@ -141,35 +367,88 @@ func (g *Guardian) fetchHistoryLog() error {
 	for _, log := range logs {
 		g.handleProposalLog(&log)
 	}
+	g.probe.backfilled.Store(true)
 
 	return nil
 }
 
 func (g *Guardian) subscribeLog() error {
-	var err error
-	g.LogSub, err = g.Client.SubscribeFilterLogs(g.Ctx, ethereum.FilterQuery{
+	sub, err := g.getClient().SubscribeFilterLogs(g.Ctx, ethereum.FilterQuery{
 		FromBlock: g.FromBlock,
 		ToBlock:   g.ToBlock,
 		Addresses: g.Addresses,
 		Topics:    g.Topics,
 	}, g.LogChan)
+	if err != nil {
+		return err
+	}
 
-	return err
+	g.clientLock.Lock()
+	old := g.LogSub
+	g.LogSub = sub
+	g.clientLock.Unlock()
+	if old != nil {
+		old.Unsubscribe()
+	}
+	g.probe.subscribed.Store(true)
+
+	return nil
+}
+
+// subscriptionErr returns the error channel of the log subscription, it is closed when unsubscribed
+func (g *Guardian) subscriptionErr() <-chan error {
+	g.clientLock.RLock()
+	defer g.clientLock.RUnlock()
+
+	if g.LogSub == nil {
+		return nil
+	}
+	return g.LogSub.Err()
 }
 
 func (g *Guardian) handleProposalLog(log *types.Log) {
+	g.metrics.lastBlock.Set(float64(log.BlockNumber))
+	// the checkpoint moves past the log after its proposal is persisted, so a restart never loses it
+	defer g.updateCheckpoint(log.BlockNumber)
+
 	proposal := &NodeProposal{}
 	if err := json.Unmarshal(log.Data, proposal); err != nil {
+		g.metrics.logs.WithLabelValues("unknown").Inc()
 		g.Logger.Errorf("unmarshal error: %s", err)
 		return
 	}
+	g.metrics.logs.WithLabelValues(proposal.Type.String()).Inc()
+	proposal.TxHash = log.TxHash.Hex()
 
 	// check proposal is node upgrade and apporved
 	if proposal.Type == NodeUpgrade && proposal.Status == Approved {
-		g.nextUpgradeProposal = proposal
+		g.persistApprovedProposal(proposal)
+		g.queue.push(proposal)
+		if g.firstDetected(proposal.ID) {
+			g.notify(EventProposalDetected, proposal.ID, "approved upgrade proposal %q to version %s in block %d", proposal.Title, proposal.Version, log.BlockNumber)
+		}
 	}
 }
 
+// firstDetected reports whether the proposal is new, logs fetched again after reconnect or restart are not
+func (g *Guardian) firstDetected(proposalID uint64) bool {
+	g.detectedLock.Lock()
+	defer g.detectedLock.Unlock()
+
+	if g.detected[proposalID] {
+		return false
+	}
+	g.detected[proposalID] = true
+
+	// applied before the last start, the dry run state belongs to the upgrade worker
+	if g.dryRun == nil {
+		if lastID, applied := g.getLastUpgradeProposal(); applied && proposalID <= lastID {
+			return false
+		}
+	}
+	return true
+}
+
 func (g *Guardian) getNewestFromBlock() *big.Int {
 	data := g.DB.Get([]byte(nextFromBlockKey))
 
@@ -184,61 +463,362 @@ func (g *Guardian) getNewestFromBlock() *big.Int {
 	return g.FromBlock
 }
 
+// updateCheckpoint moves the block the next start fetches history logs from, it never moves back.
+// The block of the log itself is fetched again, other logs of it may not be handled yet.
+func (g *Guardian) updateCheckpoint(block uint64) {
+	g.checkpointLock.Lock()
+	defer g.checkpointLock.Unlock()
+
+	if block <= g.checkpoint() {
+		return
+	}
+	if g.dryRun != nil {
+		g.dryRun.checkpoint = block
+		return
+	}
+
+	data := make([]byte, 8)
+	binary.BigEndian.PutUint64(data, block)
+	g.DB.Put([]byte(nextFromBlockKey), data)
+}
+
+// Checkpoint returns the block the next start fetches history logs from, 0 if no log is handled
+func (g *Guardian) Checkpoint() uint64 {
+	g.checkpointLock.Lock()
+	defer g.checkpointLock.Unlock()
+
+	return g.checkpoint()
+}
+
+func (g *Guardian) checkpoint() uint64 {
+	if g.dryRun != nil {
+		return g.dryRun.checkpoint
+	}
+
+	data := g.DB.Get([]byte(nextFromBlockKey))
+	if len(data) != 8 {
+		return 0
+	}
+	return binary.BigEndian.Uint64(data)
+}
+
 func (g *Guardian) listenEvents() {
 	g.Logger.Info("listen events")
 
+	heartbeat := time.NewTicker(loopHeartbeatInterval)
+	defer heartbeat.Stop()
 	for {
+		g.probe.beat()
 		select {
 		case <-g.Ctx.Done():
 			g.Logger.Info("context done")
+			return
+		case <-heartbeat.C:
 		case log := <-g.LogChan:
 			g.Logger.Infof("subscribe log: %+v", log)
-			go func() {
-				g.handleProposalLog(&log)
-				g.downloadAndRestart()
-			}()
+			g.handleProposalLog(&log)
+		case err, ok := <-g.subscriptionErr():
+			// closed by unsubscribe, the subscription is replaced on purpose
+			if !ok || err == nil {
+				continue
+			}
+			g.probe.subscribed.Store(false)
+			g.Logger.Errorf("log subscription error: %s, reconnect", err)
+			if err := g.reconnect(g.Ctx); err != nil {
+				return
+			}
 		}
 	}
 }
 
 func (g *Guardian) downloadAndRestart() {
-	// first check axiomledger current version if is newest
-	currentVersion, err := g.getAxiomLedgerCurrentVersion(filepath.Join(g.Config.AxiomPath, "axiom"))
-	if err != nil {
-		g.Logger.Errorf("get axiomledger current version error: %s", err)
-		return
+	// apply the upgrade path hop by hop, the path is planned again from the version reached by every hop
+	for g.Ctx.Err() == nil {
+		// a pause takes effect between hops, the hop in progress is finished
+		if g.queue.isPaused() {
+			g.Logger.Info("upgrades are paused")
+			return
+		}
+
+		// first check axiomledger current version if is newest
+		currentVersion, err := g.getAxiomLedgerCurrentVersion(g.installedBinaryPath())
+		if err != nil {
+			g.Logger.Errorf("get axiomledger current version error: %s", err)
+			return
+		}
+		g.metrics.setVersion("current", currentVersion.String())
+		// go on from the version reached by simulated upgrades
+		if g.dryRun != nil && g.dryRun.version != nil {
+			currentVersion = g.dryRun.version
+		}
+
+		path := g.planUpgradePath(currentVersion)
+		if len(path) == 0 {
+			g.Logger.Infof("current version %s is newest, no need upgrade", currentVersion)
+			return
+		}
+		g.Logger.Infof("upgrade path from version %s: %s", currentVersion, describeUpgradePath(path))
+
+		if err := g.upgradeHop(path[0], currentVersion); err != nil {
+			g.Logger.Errorf("upgrade by proposal %d error: %s", path[0].ID, err)
+			return
+		}
 	}
+}
 
-	newVersion := g.getNextUpgradeVersion()
-	if newVersion != "" && currentVersion == newVersion {
-		g.Logger.Infof("current version %s is newest, no need upgrade", currentVersion)
-		return
+// upgradeHop downloads and restarts axiom with the release of proposal, then checks the node is healthy.
+// Every stage is persisted in the upgrade record, so an interrupted upgrade is resumed by Start.
+func (g *Guardian) upgradeHop(proposal *NodeProposal, currentVersion *Version) error {
+	g.upgradeAudit = newUpgradeAudit(proposal, currentVersion)
+	defer func() {
+		g.upgradeAudit = nil
+	}()
+
+	upgrade := g.beginUpgrade(proposal, currentVersion)
+	if err := g.runUpgrade(upgrade, proposal, currentVersion); err != nil {
+		// interrupted by stop, the upgrade state is left for the next start to resume
+		if g.Ctx.Err() != nil {
+			g.Logger.Warnf("upgrade by proposal %d interrupted in state %s", proposal.ID, upgrade.State)
+			return err
+		}
+		g.failUpgrade(upgrade, err)
+		return err
+	}
+
+	return nil
+}
+
+func (g *Guardian) runUpgrade(upgrade *UpgradeRecord, proposal *NodeProposal, currentVersion *Version) error {
+	g.nextUpgradeProposal = proposal
+	hookCtx := &HookContext{
+		ProposalID:     proposal.ID,
+		CurrentVersion: currentVersion.String(),
+		NextVersion:    proposal.Version,
+	}
+	if _, err := g.runHooks(HookPreDownload, hookCtx); err != nil {
+		g.nextUpgradeProposal = nil
+		return err
 	}
 
 	// second download
-	downloadFilePath, err := g.download()
+	g.transition(upgrade, StateDownloading, nil)
+	downloadFilePath, err := g.download(currentVersion)
 	if err != nil {
-		g.Logger.Errorf("download error: %s", err)
-		return
+		if errors.Is(err, ErrDowngrade) || errors.Is(err, ErrPolicyDenied) {
+			g.Logger.Warnf("skip upgrade proposal %d: %s", proposal.ID, err)
+			g.recordUpgradeProposal(proposal.ID)
+			g.transition(upgrade, StateFailed, err)
+			return nil
+		}
+		g.notify(EventDownloadFailed, proposal.ID, "%s", err)
+		return fmt.Errorf("download error: %w", err)
+	}
+	upgrade.ToVersion = g.nextUpgradeVersion
+	upgrade.ReleasePath = downloadFilePath
+	if g.abortIfRequested(upgrade, downloadFilePath) {
+		return nil
+	}
+
+	hookCtx.NextVersion = g.nextUpgradeVersion
+	hookCtx.ReleasePath = downloadFilePath
+	if _, err := g.runHooks(HookPostVerify, hookCtx); err != nil {
+		_ = os.RemoveAll(downloadFilePath)
+		return err
+	}
+	g.transition(upgrade, StateVerified, nil)
+	if digest, err := fileSHA256(filepath.Join(downloadFilePath, "axiom")); err == nil {
+		g.upgradeAudit.BinaryDigest = digest
+	}
+	if g.abortIfRequested(upgrade, downloadFilePath) {
+		return nil
+	}
+
+	// the staged release is kept for inspection, axiom is left untouched
+	if g.dryRun != nil {
+		if err := g.simulateRestart(proposal, currentVersion, downloadFilePath); err != nil {
+			return err
+		}
+		g.transition(upgrade, StateDone, nil)
+		return nil
+	}
+
+	if g.Config.Approval.Enable {
+		g.transition(upgrade, StateAwaitingApproval, nil)
+		approved, err := g.awaitApproval(&PendingApproval{
+			ProposalID:  proposal.ID,
+			FromVersion: currentVersion.String(),
+			ToVersion:   g.nextUpgradeVersion,
+			ReleasePath: downloadFilePath,
+		})
+		if err != nil {
+			return fmt.Errorf("await approval error: %w", err)
+		}
+		if !approved {
+			g.Logger.Warnf("upgrade by proposal %d rejected by operator", proposal.ID)
+			_ = os.RemoveAll(downloadFilePath)
+			g.recordUpgradeProposal(proposal.ID)
+			g.transition(upgrade, StateFailed, errors.New("rejected by operator"))
+			return nil
+		}
+	}
+
+	// keep the installed release for rollback
+	previousPath, err := g.backupInstalled(currentVersion)
+	if err != nil {
+		return fmt.Errorf("backup installed release error: %w", err)
+	}
+	upgrade.PreviousPath = previousPath
+
+	// snapshot the data directory before axiom is stopped, a bad migration can be undone by restore
+	if g.snapshots != nil {
+		if _, err := g.snapshots.Create(currentVersion, filepath.Dir(g.installedBinaryPath()), proposal.ID); err != nil {
+			return fmt.Errorf("snapshot data directory error: %w", err)
+		}
+	}
+	g.transition(upgrade, StateStaged, nil)
+
+	if _, err := g.runHooks(HookPreRestart, hookCtx); err != nil {
+		return err
+	}
+	// the last chance to abort, axiom is untouched so far
+	if g.abortIfRequested(upgrade, downloadFilePath) {
+		return nil
 	}
 
 	// third restart
+	g.transition(upgrade, StateRestarting, nil)
+	g.notify(EventRestartStarted, proposal.ID, "restart axiom with release %s", downloadFilePath)
 	if err := g.restart(downloadFilePath); err != nil {
-		g.Logger.Errorf("restart error: %s", err)
-		return
+		g.notify(EventRestartFailed, proposal.ID, "%s", err)
+		// the new release may be installed already, axiom may be down on it
+		if !g.Config.HealthCheck.Rollback {
+			return fmt.Errorf("restart error: %w", err)
+		}
+		g.recordUpgradeProposal(proposal.ID)
+		return g.rollbackUpgrade(upgrade, previousPath, currentVersion, fmt.Errorf("restart error: %w", err))
+	}
+	g.recordUpgradeProposal(proposal.ID)
+
+	// make sure the node works before next hop, otherwise go back to the previous release
+	g.transition(upgrade, StateVerifying, nil)
+	if err := g.verifyUpgrade(); err != nil {
+		// the health check is canceled, not failed
+		if g.Ctx.Err() != nil {
+			return err
+		}
+		g.notify(EventRestartFailed, proposal.ID, "health check: %s", err)
+		if !g.Config.HealthCheck.Rollback {
+			return fmt.Errorf("health check after restart error: %w", err)
+		}
+		return g.rollbackUpgrade(upgrade, previousPath, currentVersion, fmt.Errorf("health check after restart error: %w", err))
+	}
+	g.notify(EventRestartSucceeded, proposal.ID, "axiom runs version %s", g.nextUpgradeVersion)
+
+	// failures of post restart hooks are only logged, the upgrade is done
+	_, _ = g.runHooks(HookPostRestart, hookCtx)
+	g.transition(upgrade, StateDone, nil)
+
+	g.Logger.Infof("upgrade to version %s successful", g.nextUpgradeVersion)
+	return nil
+}
+
+// rollbackUpgrade goes back to the previous release in previousPath after the upgrade failed for reason
+// once axiom may run the new release, the returned error tells whether the rollback succeeded
+func (g *Guardian) rollbackUpgrade(upgrade *UpgradeRecord, previousPath string, previous *Version, reason error) error {
+	if err := g.rollback(upgrade.ProposalID, previousPath, previous, reason); err != nil {
+		g.notify(EventRestartFailed, upgrade.ProposalID, "roll back to version %s: %s", previous, err)
+		return fmt.Errorf("%s, rollback error: %w", reason, err)
+	}
+	g.notify(EventRolledBack, upgrade.ProposalID, "rolled back to version %s: %s", previous, reason)
+	g.transition(upgrade, StateRolledBack, reason)
+	return fmt.Errorf("%w, rolled back", reason)
+}
+
+// verifyUpgrade reconnects the restarted axiom and checks it runs the staged version healthily
+func (g *Guardian) verifyUpgrade() error {
+	staged, err := ParseVersion(g.nextUpgradeVersion)
+	if err != nil {
+		return err
+	}
+
+	if err := g.reconnectForHealthCheck(); err != nil {
+		return err
 	}
+
+	return g.checkHealth(staged)
 }
 
-func (g *Guardian) download() (string, error) {
+// reconnectForHealthCheck reconnects the restarted axiom, its rpc must come back within the health check timeout
+func (g *Guardian) reconnectForHealthCheck() error {
+	ctx, cancel := context.WithTimeout(g.Ctx, g.Config.HealthCheck.Timeout)
+	defer cancel()
+
+	if err := g.reconnect(ctx); err != nil {
+		return fmt.Errorf("rpc is not available: %w", err)
+	}
+	return nil
+}
+
+// checkCompatible checks the installed version against the source version constraint of the proposal
+func (g *Guardian) checkCompatible(proposal *NodeProposal, currentVersion *Version) error {
+	if proposal.SourceVersionConstraint == "" {
+		return nil
+	}
+
+	constraint, err := ParseConstraint(proposal.SourceVersionConstraint)
+	if err != nil {
+		return fmt.Errorf("proposal %d: %w", proposal.ID, err)
+	}
+
+	if !constraint.Check(currentVersion) {
+		return fmt.Errorf("%w: proposal %d requires source version %s, installed version is %s",
+			ErrIncompatible, proposal.ID, constraint, currentVersion)
+	}
+
+	return nil
+}
+
+func (g *Guardian) download(currentVersion *Version) (string, error) {
 	if g.nextUpgradeProposal == nil {
 		g.Logger.Info("nothing to download")
 		return "", nil
 	}
 
-	downloadUrls := g.nextUpgradeProposal.DownloadUrls
-	checkHash := g.nextUpgradeProposal.CheckHash
+	// prefer the binary patch, fall back to the full release if it can not be applied
+	if g.nextUpgradeProposal.Delta != nil {
+		axiomLedgerPath, err := g.downloadDelta(g.nextUpgradeProposal.Delta, currentVersion)
+		if err == nil {
+			return g.stageRelease(axiomLedgerPath, currentVersion)
+		}
+		g.Logger.Warnf("apply delta upgrade failed, fall back to full download: %s", err)
+	}
 
-	maxInt := big.NewInt(int64(len(downloadUrls)))
+	filePath, err := g.fetch(g.nextUpgradeProposal.DownloadUrls, g.nextUpgradeProposal.CheckHash)
+	if err != nil {
+		return "", err
+	}
+
+	axiomLedgerPath, err := g.decompress(filePath)
+	if err != nil {
+		return "", err
+	}
+	g.Logger.Debugf("axiom ledger path: %s", axiomLedgerPath)
+
+	// refuse the release if extracted tree not match the manifest
+	if err := g.verifyRelease(axiomLedgerPath); err != nil {
+		if err := os.RemoveAll(axiomLedgerPath); err != nil {
+			g.Logger.Errorf("remove invalid release %s error: %s", axiomLedgerPath, err)
+		}
+		return "", err
+	}
+	g.Logger.Infof("release manifest check passed")
+
+	return g.stageRelease(axiomLedgerPath, currentVersion)
+}
+
+// fetch downloads the file from a random url of urls and checks its sha256
+func (g *Guardian) fetch(urls []string, checkHash string) (string, error) {
+	maxInt := big.NewInt(int64(len(urls)))
 
 	if maxInt.Int64() <= 0 {
 		return "", errors.New("download url list is empty")
@@ -246,15 +826,21 @@ func (g *Guardian) download() (string, error) {
 
 	var filePath string
 
-	handle := func(urls []string) error {
+	handle := func() (err error) {
 		index, err := rand.Int(rand.Reader, maxInt)
 		if err != nil {
 			return err
 		}
 
-		downloadUrl := downloadUrls[index.Uint64()]
+		downloadUrl := urls[index.Uint64()]
 		g.Logger.Debugf("download url: %s", downloadUrl)
 
+		start := time.Now()
+		var written int64
+		defer func() {
+			g.metrics.observeDownload(downloadUrl, written, time.Since(start), err)
+		}()
+
 		downloadPath := filepath.Join(g.Config.RepoRoot, "download")
 		if _, err := os.Stat(downloadPath); err != nil {
 			if err := os.Mkdir(downloadPath, 0775); err != nil {
@@ -262,7 +848,11 @@ func (g *Guardian) download() (string, error) {
 			}
 		}
 
-		resp, err := http.Get(downloadUrl)
+		req, err := http.NewRequestWithContext(g.Ctx, http.MethodGet, downloadUrl, nil)
+		if err != nil {
+			return err
+		}
+		resp, err := http.DefaultClient.Do(req)
 		if err != nil {
 			return err
 		}
@@ -274,14 +864,16 @@ func (g *Guardian) download() (string, error) {
 
 		filename := path.Base(downloadUrl)
 		filePath = filepath.Join(downloadPath, filename)
-		downloadFile, err := os.OpenFile(filePath, os.O_RDWR|os.O_CREATE, 0755)
+		downloadFile, err := os.OpenFile(filePath, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0755)
 		if err != nil {
 			return err
 		}
 		defer downloadFile.Close()
 
-		_, err = io.Copy(downloadFile, resp.Body)
+		written, err = io.Copy(downloadFile, resp.Body)
 		if err != nil {
+			// do not leave a partial download behind
+			_ = os.Remove(filePath)
 			return err
 		}
 
@@ -290,14 +882,14 @@ func (g *Guardian) download() (string, error) {
 
 	// retry download if failed
 	action := func(attempt uint) error {
-		if err := handle(downloadUrls); err != nil {
+		if err := handle(); err != nil {
 			return err
 		}
 
 		return nil
 	}
 	// TODO: need retry when network down
-	if err := retry.Retry(action, strategy.Limit(5), strategy.Backoff(backoff.Fibonacci(5*time.Second))); err != nil {
+	if err := retry.Retry(action, strategy.Limit(5), g.backoffUntilStop(backoff.Fibonacci(5*time.Second))); err != nil {
 		return "", err
 	}
 
@@ -307,36 +899,71 @@ func (g *Guardian) download() (string, error) {
 
 	g.Logger.Infof("download file hash check passed")
 
-	// get axiomledger version
-	axiomLedgerPath := g.decompress(filePath)
-	g.Logger.Debugf("axiom ledger path: %s", axiomLedgerPath)
+	return filePath, nil
+}
+
+// stageRelease records the version of the release in axiomLedgerPath as the next upgrade version,
+// a release not newer than the current version is refused unless the proposal is a rollback
+func (g *Guardian) stageRelease(axiomLedgerPath string, currentVersion *Version) (string, error) {
 	nextUpgradeVersion, err := g.getAxiomLedgerCurrentVersion(filepath.Join(axiomLedgerPath, "axiom"))
 	if err != nil {
 		return "", err
 	}
-	g.nextUpgradeVersion = nextUpgradeVersion
+
+	if !g.nextUpgradeProposal.Rollback && nextUpgradeVersion.Compare(currentVersion) <= 0 {
+		return "", g.refuseRelease(axiomLedgerPath, fmt.Errorf("%w: release version %s, current version %s", ErrDowngrade, nextUpgradeVersion, currentVersion))
+	}
+
+	if err := g.getPolicy().CheckVersion(nextUpgradeVersion); err != nil {
+		return "", g.refuseRelease(axiomLedgerPath, err)
+	}
+
+	if declared := g.nextUpgradeProposal.Version; declared != "" {
+		declaredVersion, err := ParseVersion(declared)
+		if err != nil || declaredVersion.Compare(nextUpgradeVersion) != 0 {
+			return "", g.refuseRelease(axiomLedgerPath, fmt.Errorf("%w: release version %s not match proposal version %s", ErrPolicyDenied, nextUpgradeVersion, declared))
+		}
+	}
+
+	// the archive digest says nothing of the binary inside it, a denied binary may be repackaged
+	binaryDigest, err := fileSHA256(filepath.Join(axiomLedgerPath, "axiom"))
+	if err != nil {
+		return "", g.refuseRelease(axiomLedgerPath, fmt.Errorf("hash axiom binary: %w", err))
+	}
+	if err := g.getPolicy().CheckDigest(binaryDigest); err != nil {
+		return "", g.refuseRelease(axiomLedgerPath, err)
+	}
+
+	g.nextUpgradeVersion = nextUpgradeVersion.String()
+	g.metrics.setVersion("staged", g.nextUpgradeVersion)
 
 	g.nextUpgradeProposal = nil
 
 	return axiomLedgerPath, nil
 }
 
-func (g *Guardian) checkFileHash(filePath, hash string) bool {
-	f, err := os.OpenFile(filePath, os.O_RDONLY, 0755)
-	if err != nil {
-		g.Logger.Errorf("open download file error: %s", err)
-		return false
+// refuseRelease drops the staged release in axiomLedgerPath and the proposal it was downloaded for
+func (g *Guardian) refuseRelease(axiomLedgerPath string, reason error) error {
+	g.nextUpgradeProposal = nil
+	if err := os.RemoveAll(axiomLedgerPath); err != nil {
+		g.Logger.Errorf("remove refused release %s error: %s", axiomLedgerPath, err)
 	}
-	defer f.Close()
+	return reason
+}
 
-	h := sha256.New()
-	if _, err := io.Copy(h, f); err != nil {
-		g.Logger.Errorf("copy file to sha256 error: %s", err)
+func (g *Guardian) checkFileHash(filePath, hash string) bool {
+	sum, err := fileSHA256(filePath)
+	if err != nil {
+		g.metrics.hashCheckFailures.Inc()
+		g.Logger.Errorf("compute download file sha256 error: %s", err)
 		return false
 	}
 
-	sum := fmt.Sprintf("%x", h.Sum(nil))
 	if sum != hash {
+		g.metrics.hashCheckFailures.Inc()
+		if upgrade := g.ActiveUpgrade(); upgrade != nil {
+			g.notify(EventHashMismatch, upgrade.ProposalID, "%s: expected sha256 %s, got %s", filepath.Base(filePath), hash, sum)
+		}
 		g.Logger.Errorf("file hash mismatch, source file hash: %s, target file hash: %s", hash, sum)
 		return false
 	}
@@ -349,117 +976,189 @@ func (g *Guardian) restart(downloadFilePath string) error {
 		return nil
 	}
 
-	// check restart.sh existence
-	if _, err := os.Stat(filepath.Join(g.Config.AxiomPath, "restart.sh")); err != nil {
+	version, err := ParseVersion(g.nextUpgradeVersion)
+	if err != nil {
 		return err
 	}
 
-	// execute restart shell
-	execCmd := fmt.Sprintf("cd %s && bash restart.sh %s", g.Config.AxiomPath, filepath.Join(downloadFilePath, "axiom"))
-
-	g.Logger.Debugf("exec restart command: %s", execCmd)
+	binaryPath, err := g.activate(downloadFilePath, version)
+	if err != nil {
+		return err
+	}
 
-	cmd := exec.Command("bash", "-c", execCmd)
-	if _, err := cmd.Output(); err != nil {
+	// a restart is not interrupted by stop, a half done restart is worse than a late exit
+	err = g.restarter.Restart(context.Background(), binaryPath)
+	g.metrics.observeRestart(restartReasonUpgrade, err)
+	if err != nil {
 		return err
 	}
 
 	// record restart version
 	g.DB.Put([]byte(nextUpgradeVersion), []byte(g.nextUpgradeVersion))
-
-	// reconnect new axiom after restart
-	if err := g.reconnect(); err != nil {
-		return err
-	}
+	g.metrics.setVersion("current", g.nextUpgradeVersion)
 
 	g.Logger.Infof("restart successful")
 	return nil
 }
 
-func (g *Guardian) reconnect() error {
-	var client Client
+// Stop cancels the upgrade stages which can be redone and waits for the goroutines up to the shutdown timeout,
+// then closes the db. A restart in progress is not interrupted.
+// If the goroutines do not exit in time the db is left open, the upgrade state is resumed by the next start.
+func (g *Guardian) Stop() error {
 	var err error
+	g.stopOnce.Do(func() {
+		err = g.stop()
+	})
+	return err
+}
 
-	action := func(attempt uint) error {
-		client, err = ethclient.DialContext(g.Ctx, g.Config.DialUrl)
-		if err != nil {
-			return err
+func (g *Guardian) stop() error {
+	g.cancel()
+	g.clientLock.RLock()
+	if g.LogSub != nil {
+		g.LogSub.Unsubscribe()
+	}
+	g.clientLock.RUnlock()
+
+	if err := g.control.Stop(); err != nil {
+		g.Logger.Errorf("stop control server error: %s", err)
+	}
+
+	if g.metricsServer != nil {
+		if err := g.metricsServer.Stop(); err != nil {
+			g.Logger.Errorf("stop metrics server error: %s", err)
 		}
+	}
 
-		return nil
+	if g.probeServer != nil {
+		if err := g.probeServer.Stop(); err != nil {
+			g.Logger.Errorf("stop probe server error: %s", err)
+		}
 	}
 
-	if err = retry.Retry(action, strategy.Limit(5), strategy.Backoff(backoff.Fibonacci(5*time.Second))); err != nil {
-		return err
+	if g.debugServer != nil {
+		if err := g.debugServer.Stop(); err != nil {
+			g.Logger.Errorf("stop debug server error: %s", err)
+		}
 	}
 
-	g.Client = client
+	// the events of the last stages are delivered in the shutdown timeout as well
+	exited := make(chan struct{})
+	go func() {
+		g.wg.Wait()
+		g.notifier.Wait()
+		close(exited)
+	}()
+
+	var waitErr error
+	select {
+	case <-exited:
+	case <-time.After(g.Config.ShutdownTimeout):
+		waitErr = fmt.Errorf("guardian not exit in %s", g.Config.ShutdownTimeout)
+	}
+	g.notifyCancel()
 
-	if err := g.subscribeLog(); err != nil {
-		return err
+	// axiom is stopped after the upgrade worker, so it is never stopped in the middle of a restart
+	if lifecycle, ok := g.restarter.(restarterLifecycle); ok && g.dryRun == nil {
+		if err := lifecycle.Stop(); err != nil {
+			return err
+		}
 	}
-	return nil
+
+	if waitErr != nil {
+		return waitErr
+	}
+
+	return g.DB.Close()
 }
 
-func (g *Guardian) Stop() error {
-	g.LogSub.Unsubscribe()
+// backoffUntilStop is a retry strategy like strategy.Backoff, but it stops waiting and retrying once guardian is stopped
+func (g *Guardian) backoffUntilStop(algorithm backoff.Algorithm) strategy.Strategy {
+	return func(attempt uint) bool {
+		if attempt > 0 {
+			timer := time.NewTimer(algorithm(attempt))
+			defer timer.Stop()
+			select {
+			case <-timer.C:
+			case <-g.Ctx.Done():
+				return false
+			}
+		}
 
-	return nil
+		return g.Ctx.Err() == nil
+	}
 }
 
-func (g *Guardian) getAxiomLedgerCurrentVersion(p string) (string, error) {
+func (g *Guardian) getAxiomLedgerCurrentVersion(p string) (*Version, error) {
 	dir := filepath.Dir(p)
 
 	// check version.sh existence
 	if _, err := os.Stat(filepath.Join(dir, "version.sh")); err != nil {
-		return "", err
+		return nil, err
 	}
 
 	execCmd := fmt.Sprintf("cd %s && bash version.sh", dir)
-	cmd := exec.Command("bash", "-c", execCmd)
+	cmd := exec.CommandContext(g.Ctx, "bash", "-c", execCmd)
 
 	out, err := cmd.Output()
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
-	strList := strings.Split(string(out), ": ")
-	if len(strList) < 2 {
-		return "", errors.New("version command not contains :")
+	version, err := parseVersionOutput(string(out))
+	if err != nil {
+		return nil, err
 	}
-	strList = strings.Split(strList[1], "\n")
 
-	g.Logger.Infof("version is: %s", strList[0])
+	g.Logger.Infof("version is: %s", version)
 
-	return strList[0], nil
+	return version, nil
 }
 
-func (g *Guardian) getNextUpgradeVersion() string {
-	versionData := g.DB.Get([]byte(nextUpgradeVersion))
-	g.nextUpgradeVersion = string(versionData)
-	return g.nextUpgradeVersion
+func (g *Guardian) verifyRelease(dir string) error {
+	manifest, err := LoadManifest(dir)
+	if err != nil {
+		return err
+	}
+
+	if err := manifest.Validate(dir); err != nil {
+		return fmt.Errorf("validate release manifest: %w", err)
+	}
+
+	return nil
 }
 
-func (g *Guardian) decompress(p string) string {
+func (g *Guardian) decompress(p string) (string, error) {
 	dir := filepath.Dir(p)
 	filename := filepath.Base(p)
 
 	// decompress to new directory
-	dstDirName := fmt.Sprintf("axiom-%d", time.Now().Unix())
-	dstPath := filepath.Join(dir, dstDirName)
-	if err := os.Mkdir(dstPath, 0755); err != nil {
-		g.Logger.Errorf("mkdir %s error: %s", dstPath, err)
-		return ""
+	dstPath, err := g.newReleaseDir()
+	if err != nil {
+		return "", err
 	}
+	dstDirName := filepath.Base(dstPath)
 
-	execCmd := fmt.Sprintf("cd %s && tar -zxvf %s -C ./%s", dir, filename, dstDirName)
+	// keep file permissions, the manifest check compares them
+	execCmd := fmt.Sprintf("cd %s && tar -zxvpf %s -C ./%s", dir, filename, dstDirName)
 	g.Logger.Debugf("execute command: %s", execCmd)
-	cmd := exec.Command("bash", "-c", execCmd)
+	cmd := exec.CommandContext(g.Ctx, "bash", "-c", execCmd)
 
 	if _, err := cmd.Output(); err != nil {
-		g.Logger.Errorf("decompress file error: %s", err)
-		return dstPath
+		// do not leave a half extracted release behind
+		_ = os.RemoveAll(dstPath)
+		return "", fmt.Errorf("decompress file error: %w", err)
+	}
+
+	return dstPath, nil
+}
+
+// newReleaseDir creates an empty directory under the download path for a new release
+func (g *Guardian) newReleaseDir() (string, error) {
+	dstPath := filepath.Join(g.Config.RepoRoot, "download", fmt.Sprintf("axiom-%d", time.Now().UnixNano()))
+	if err := os.MkdirAll(dstPath, 0755); err != nil {
+		return "", fmt.Errorf("mkdir %s error: %w", dstPath, err)
 	}
 
-	return dstPath
+	return dstPath, nil
 }
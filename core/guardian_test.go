@@ -2,6 +2,8 @@ package core
 
 import (
 	"context"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
 	"testing"
@@ -23,7 +25,7 @@ func TestStart(t *testing.T) {
 	c.AxiomPath = filepath.Join(dir, "test_axiom")
 	c.Log.Level = "debug"
 
-	guardian, err := NewGuardian(context.Background(), c, &MockClient{})
+	guardian, err := NewGuardian(context.Background(), c, StaticClientFactory(&MockClient{}))
 	assert.Nil(t, err)
 
 	err = guardian.Start()
@@ -31,3 +33,102 @@ func TestStart(t *testing.T) {
 
 	time.Sleep(5 * time.Second)
 }
+
+func TestCheckCompatible(t *testing.T) {
+	c := repo.DefaultConfig(t.TempDir())
+	guardian, err := NewGuardian(context.Background(), c, StaticClientFactory(&MockClient{}))
+	assert.Nil(t, err)
+
+	current := &Version{Major: 1, Minor: 3}
+	proposal := &NodeProposal{}
+	assert.Nil(t, guardian.checkCompatible(proposal, current))
+
+	proposal.SourceVersionConstraint = ">=1.2.0 <1.4.0"
+	assert.Nil(t, guardian.checkCompatible(proposal, current))
+
+	proposal.SourceVersionConstraint = ">=1.4.0"
+	assert.ErrorIs(t, guardian.checkCompatible(proposal, current), ErrIncompatible)
+
+	proposal.SourceVersionConstraint = "latest"
+	assert.ErrorIs(t, guardian.checkCompatible(proposal, current), ErrInvalidConstraint)
+}
+
+func TestPlanUpgradePath(t *testing.T) {
+	c := repo.DefaultConfig(t.TempDir())
+	guardian, err := NewGuardian(context.Background(), c, StaticClientFactory(&MockClient{}))
+	assert.Nil(t, err)
+
+	newProposal := func(id uint64, version, constraint string) *NodeProposal {
+		return &NodeProposal{
+			BaseProposal:            BaseProposal{ID: id, Type: NodeUpgrade, Status: Approved},
+			Version:                 version,
+			SourceVersionConstraint: constraint,
+		}
+	}
+
+	// added out of order, replaced by id
+	guardian.addApprovedProposal(newProposal(3, "1.3.0", ">=1.2.0 <1.3.0"))
+	guardian.addApprovedProposal(newProposal(1, "1.1.0", ""))
+	guardian.addApprovedProposal(newProposal(2, "1.2.0", ">=1.1.0 <1.2.0"))
+	guardian.addApprovedProposal(newProposal(4, "2.0.0", ">=1.0.0 <1.2.0"))
+	guardian.addApprovedProposal(newProposal(5, "1.4.0", ">=1.3.0"))
+	guardian.addApprovedProposal(newProposal(5, "1.4.0", "=1.3.0"))
+
+	ids := func(path []*NodeProposal) []uint64 {
+		var res []uint64
+		for _, p := range path {
+			res = append(res, p.ID)
+		}
+		return res
+	}
+
+	// proposal 4 is compatible with 1.0.0 but not with 1.2.0 reached by proposal 2
+	assert.Equal(t, []uint64{1, 2, 3, 5}, ids(guardian.planUpgradePath(&Version{Major: 1})))
+	assert.Equal(t, []uint64{3, 5}, ids(guardian.planUpgradePath(&Version{Major: 1, Minor: 2})))
+	assert.Empty(t, guardian.planUpgradePath(&Version{Major: 1, Minor: 4}))
+
+	guardian.recordUpgradeProposal(3)
+	assert.Equal(t, []uint64{5}, ids(guardian.planUpgradePath(&Version{Major: 1, Minor: 3})))
+
+	// unknown version ends the path
+	guardian.addApprovedProposal(newProposal(6, "", ""))
+	guardian.addApprovedProposal(newProposal(7, "1.5.0", ""))
+	assert.Equal(t, []uint64{5, 6}, ids(guardian.planUpgradePath(&Version{Major: 1, Minor: 3})))
+}
+
+func TestStop(t *testing.T) {
+	c := repo.DefaultConfig(t.TempDir())
+	c.AxiomPath = t.TempDir()
+	c.ShutdownTimeout = 5 * time.Second
+	guardian, err := NewGuardian(context.Background(), c, StaticClientFactory(&MockClient{}))
+	assert.Nil(t, err)
+	assert.Nil(t, guardian.Start())
+
+	// a download in progress is canceled by stop
+	block := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-block
+	}))
+	defer server.Close()
+	defer close(block)
+
+	fetched := make(chan error, 1)
+	go func() {
+		_, err := guardian.fetch([]string{server.URL + "/axiom.tar.gz"}, "")
+		fetched <- err
+	}()
+	time.Sleep(100 * time.Millisecond)
+
+	start := time.Now()
+	assert.Nil(t, guardian.Stop())
+	assert.Less(t, time.Since(start), time.Second)
+	assert.Nil(t, guardian.Stop())
+
+	select {
+	case err := <-fetched:
+		assert.ErrorIs(t, err, context.Canceled)
+	case <-time.After(time.Second):
+		t.Fatal("download not canceled by stop")
+	}
+	assert.NoFileExists(t, filepath.Join(c.RepoRoot, repo.ControlSocketName))
+}
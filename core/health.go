@@ -0,0 +1,78 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// checkHealth verifies the restarted axiom: the rpc comes back and reports the expected version,
+// then the block height advances and the peer count reaches the configured minimum
+func (g *Guardian) checkHealth(expected *Version) error {
+	var height uint64
+	err := g.poll(g.Config.HealthCheck.Timeout, func(ctx context.Context) error {
+		clientVersion, err := g.getClient().ClientVersion(ctx)
+		if err != nil {
+			return fmt.Errorf("rpc is not available: %w", err)
+		}
+
+		reported, err := ExtractVersion(clientVersion)
+		if err != nil {
+			return err
+		}
+		if reported.Compare(expected) != 0 {
+			return fmt.Errorf("reported version %s is not the expected version %s", reported, expected)
+		}
+
+		height, err = g.getClient().BlockNumber(ctx)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	g.Logger.Infof("axiom %s is serving, block height: %d", expected, height)
+
+	return g.poll(g.Config.HealthCheck.BlockAdvanceTimeout, func(ctx context.Context) error {
+		current, err := g.getClient().BlockNumber(ctx)
+		if err != nil {
+			return err
+		}
+		if current <= height {
+			return fmt.Errorf("block height not advance from %d", height)
+		}
+
+		if minPeers := g.Config.HealthCheck.MinPeers; minPeers > 0 {
+			peers, err := g.getClient().PeerCount(ctx)
+			if err != nil {
+				return err
+			}
+			if peers < minPeers {
+				return fmt.Errorf("peer count %d is less than %d", peers, minPeers)
+			}
+		}
+
+		return nil
+	})
+}
+
+// poll calls check every interval until it succeeds, the last error is returned after timeout
+func (g *Guardian) poll(timeout time.Duration, check func(ctx context.Context) error) error {
+	ctx, cancel := context.WithTimeout(g.Ctx, timeout)
+	defer cancel()
+
+	ticker := time.NewTicker(g.Config.HealthCheck.Interval)
+	defer ticker.Stop()
+
+	for {
+		err := check(ctx)
+		if err == nil {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return err
+		case <-ticker.C:
+		}
+	}
+}
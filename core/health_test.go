@@ -0,0 +1,109 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/axiomesh/guardian/repo"
+	"github.com/stretchr/testify/assert"
+)
+
+type healthClient struct {
+	MockClient
+	version string
+	height  uint64
+	peers   uint64
+}
+
+func (hc *healthClient) BlockNumber(ctx context.Context) (uint64, error) {
+	if hc.height != 0 {
+		return hc.height, nil
+	}
+	return hc.MockClient.BlockNumber(ctx)
+}
+
+func (hc *healthClient) PeerCount(ctx context.Context) (uint64, error) {
+	return hc.peers, nil
+}
+
+func (hc *healthClient) ClientVersion(ctx context.Context) (string, error) {
+	return hc.version, nil
+}
+
+func TestCheckHealth(t *testing.T) {
+	c := repo.DefaultConfig(t.TempDir())
+	c.HealthCheck.Timeout = 100 * time.Millisecond
+	c.HealthCheck.BlockAdvanceTimeout = 100 * time.Millisecond
+	c.HealthCheck.Interval = 10 * time.Millisecond
+	c.HealthCheck.MinPeers = 2
+
+	client := &healthClient{version: "axiom/v1.1.0/linux-amd64/go1.20.5", peers: 3}
+	guardian, err := NewGuardian(context.Background(), c, StaticClientFactory(client))
+	assert.Nil(t, err)
+
+	expected := &Version{Major: 1, Minor: 1}
+	assert.Nil(t, guardian.checkHealth(expected))
+
+	client.version = "axiom/v1.0.0/linux-amd64/go1.20.5"
+	assert.ErrorContains(t, guardian.checkHealth(expected), "not the expected version")
+
+	client.version = "axiom/v1.1.0/linux-amd64/go1.20.5"
+	client.peers = 1
+	assert.ErrorContains(t, guardian.checkHealth(expected), "peer count")
+
+	client.peers = 3
+	client.height = 1000
+	assert.ErrorContains(t, guardian.checkHealth(expected), "block height not advance")
+}
+
+func TestBackupInstalled(t *testing.T) {
+	c := repo.DefaultConfig(t.TempDir())
+	c.AxiomPath = t.TempDir()
+	assert.Nil(t, os.WriteFile(filepath.Join(c.AxiomPath, "axiom"), []byte("axiom v1.0.0"), 0755))
+	assert.Nil(t, os.WriteFile(filepath.Join(c.AxiomPath, "version.sh"), []byte("echo 'Axiom version: v1.0.0'"), 0755))
+
+	guardian, err := NewGuardian(context.Background(), c, StaticClientFactory(&MockClient{}))
+	assert.Nil(t, err)
+
+	backupPath, err := guardian.backupInstalled(&Version{Major: 1})
+	assert.Nil(t, err)
+	assert.Equal(t, filepath.Join(c.RepoRoot, "backup", "axiom-1.0.0"), backupPath)
+
+	data, err := os.ReadFile(filepath.Join(backupPath, "axiom"))
+	assert.Nil(t, err)
+	assert.Equal(t, "axiom v1.0.0", string(data))
+	assert.FileExists(t, filepath.Join(backupPath, "version.sh"))
+
+	record, err := guardian.getRollbackRecord(1)
+	assert.Nil(t, err)
+	assert.Nil(t, record)
+}
+
+func TestRollbackUpgrade(t *testing.T) {
+	c := repo.DefaultConfig(t.TempDir())
+	c.Restart.Mode = repo.RestartModeNoop
+	c.HealthCheck.Timeout = 100 * time.Millisecond
+	c.HealthCheck.BlockAdvanceTimeout = 100 * time.Millisecond
+	c.HealthCheck.Interval = 10 * time.Millisecond
+	guardian, err := NewGuardian(context.Background(), c, StaticClientFactory(&healthClient{version: "axiom/v1.0.0/linux-amd64/go1.20.5"}))
+	assert.Nil(t, err)
+
+	// the restart of the new release failed, axiom is back on the previous one
+	previousPath, _ := writeRelease(t, map[string]string{"axiom": "axiom v1.0.0", "version.sh": "echo 'Axiom version: v1.0.0'"})
+	upgrade := guardian.beginUpgrade(&NodeProposal{BaseProposal: BaseProposal{ID: 2}, Version: "1.1.0"}, &Version{Major: 1})
+	guardian.transition(upgrade, StateRestarting, nil)
+	guardian.nextUpgradeVersion = "1.1.0"
+	err = guardian.rollbackUpgrade(upgrade, previousPath, &Version{Major: 1}, errors.New("restart error: unit not found"))
+	assert.ErrorContains(t, err, "restart error: unit not found, rolled back")
+
+	record, err := guardian.GetUpgradeRecord(2)
+	assert.Nil(t, err)
+	assert.Equal(t, StateRolledBack, record.State)
+	rollback, err := guardian.getRollbackRecord(2)
+	assert.Nil(t, err)
+	assert.Equal(t, "1.0.0", rollback.ToVersion)
+}
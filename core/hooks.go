@@ -0,0 +1,164 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"time"
+
+	"github.com/axiomesh/guardian/repo"
+)
+
+const (
+	defaultHookTimeout = time.Minute
+
+	// hookOutputLimit is the max bytes of hook output kept
+	hookOutputLimit = 64 * 1024
+)
+
+type HookPhase string
+
+const (
+	HookPreDownload HookPhase = "pre-download"
+	HookPostVerify  HookPhase = "post-verify"
+	HookPreRestart  HookPhase = "pre-restart"
+	HookPostRestart HookPhase = "post-restart"
+)
+
+// abortive reports whether a failing hook of the phase aborts the upgrade
+func (p HookPhase) abortive() bool {
+	return p != HookPostRestart
+}
+
+// HookContext is written to stdin of hooks in json
+type HookContext struct {
+	Phase          HookPhase `json:"phase"`
+	ProposalID     uint64    `json:"proposal_id"`
+	CurrentVersion string    `json:"current_version"`
+	NextVersion    string    `json:"next_version,omitempty"`
+	ReleasePath    string    `json:"release_path,omitempty"`
+	AxiomPath      string    `json:"axiom_path"`
+	RepoRoot       string    `json:"repo_root"`
+}
+
+type HookResult struct {
+	Name     string        `json:"name"`
+	Phase    HookPhase     `json:"phase"`
+	Output   string        `json:"output"`
+	Error    string        `json:"error,omitempty"`
+	Duration time.Duration `json:"duration"`
+}
+
+func (g *Guardian) hooksOf(phase HookPhase) []repo.Hook {
+	switch phase {
+	case HookPreDownload:
+		return g.Config.Hooks.PreDownload
+	case HookPostVerify:
+		return g.Config.Hooks.PostVerify
+	case HookPreRestart:
+		return g.Config.Hooks.PreRestart
+	case HookPostRestart:
+		return g.Config.Hooks.PostRestart
+	default:
+		return nil
+	}
+}
+
+// runHooks runs the hooks of the phase in order.
+// A failing hook of an abortive phase stops the remaining hooks and its error is returned,
+// failures of other phases are only logged.
+// Hooks are not run in dry run mode, they are listed in the dry run record instead.
+func (g *Guardian) runHooks(phase HookPhase, hookCtx *HookContext) ([]*HookResult, error) {
+	hooks := g.hooksOf(phase)
+	if len(hooks) == 0 {
+		return nil, nil
+	}
+	if g.dryRun != nil {
+		for _, hook := range hooks {
+			g.Logger.Infof("dry run: skip %s hook %s", phase, hook.Name)
+		}
+		return nil, nil
+	}
+
+	hookCtx.Phase = phase
+	hookCtx.AxiomPath = g.Config.AxiomPath
+	hookCtx.RepoRoot = g.Config.RepoRoot
+	input, err := json.Marshal(hookCtx)
+	if err != nil {
+		return nil, err
+	}
+
+	var results []*HookResult
+	for _, hook := range hooks {
+		result := g.runHook(phase, hook, input)
+		results = append(results, result)
+		if g.upgradeAudit != nil {
+			g.upgradeAudit.Hooks = append(g.upgradeAudit.Hooks, result)
+		}
+
+		if result.Error == "" {
+			g.Logger.Infof("%s hook %s succeeded in %s", phase, hook.Name, result.Duration)
+			continue
+		}
+
+		g.Logger.Errorf("%s hook %s failed: %s, output: %s", phase, hook.Name, result.Error, result.Output)
+		if phase.abortive() {
+			return results, fmt.Errorf("%s hook %s failed: %s", phase, hook.Name, result.Error)
+		}
+	}
+
+	return results, nil
+}
+
+func (g *Guardian) runHook(phase HookPhase, hook repo.Hook, input []byte) *HookResult {
+	timeout := hook.Timeout
+	if timeout <= 0 {
+		timeout = defaultHookTimeout
+	}
+	ctx, cancel := context.WithTimeout(g.Ctx, timeout)
+	defer cancel()
+
+	var output limitedBuffer
+	cmd := exec.CommandContext(ctx, "bash", "-c", hook.Command)
+	cmd.Dir = g.Config.RepoRoot
+	cmd.Stdin = bytes.NewReader(input)
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+	// do not wait for the pipes held by background processes of a killed hook
+	cmd.WaitDelay = time.Second
+
+	g.Logger.Debugf("run %s hook %s: %s", phase, hook.Name, hook.Command)
+	start := time.Now()
+	err := cmd.Run()
+	result := &HookResult{
+		Name:     hook.Name,
+		Phase:    phase,
+		Output:   output.String(),
+		Duration: time.Since(start),
+	}
+	if ctx.Err() == context.DeadlineExceeded {
+		result.Error = fmt.Sprintf("timeout after %s", timeout)
+	} else if err != nil {
+		result.Error = err.Error()
+	}
+
+	return result
+}
+
+// limitedBuffer keeps the first hookOutputLimit bytes written
+type limitedBuffer struct {
+	bytes.Buffer
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if remain := hookOutputLimit - b.Len(); remain > 0 {
+		if len(p) > remain {
+			b.Buffer.Write(p[:remain])
+		} else {
+			b.Buffer.Write(p)
+		}
+	}
+	return len(p), nil
+}
@@ -0,0 +1,68 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/axiomesh/guardian/repo"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunHooks(t *testing.T) {
+	c := repo.DefaultConfig(t.TempDir())
+	stdinPath := filepath.Join(c.RepoRoot, "stdin.json")
+	markPath := filepath.Join(c.RepoRoot, "mark")
+	c.Hooks.PreDownload = []repo.Hook{
+		{Name: "save", Command: "cat > " + stdinPath + " && echo saved"},
+		{Name: "fail", Command: "echo drain failed && exit 3"},
+		{Name: "mark", Command: "touch " + markPath},
+	}
+	c.Hooks.PreRestart = []repo.Hook{
+		{Name: "slow", Command: "sleep 5", Timeout: 100 * time.Millisecond},
+	}
+	c.Hooks.PostRestart = []repo.Hook{
+		{Name: "fail", Command: "exit 1"},
+		{Name: "mark", Command: "touch " + markPath},
+	}
+
+	guardian, err := NewGuardian(context.Background(), c, StaticClientFactory(&MockClient{}))
+	assert.Nil(t, err)
+
+	hookCtx := &HookContext{ProposalID: 7, CurrentVersion: "1.0.0", NextVersion: "1.1.0"}
+	results, err := guardian.runHooks(HookPreDownload, hookCtx)
+	assert.ErrorContains(t, err, "pre-download hook fail failed")
+	assert.Equal(t, 2, len(results))
+	assert.Equal(t, "saved\n", results[0].Output)
+	assert.Equal(t, "drain failed\n", results[1].Output)
+	assert.Contains(t, results[1].Error, "exit status 3")
+	assert.NoFileExists(t, markPath)
+
+	data, err := os.ReadFile(stdinPath)
+	assert.Nil(t, err)
+	received := &HookContext{}
+	assert.Nil(t, json.Unmarshal(data, received))
+	assert.Equal(t, HookPreDownload, received.Phase)
+	assert.Equal(t, uint64(7), received.ProposalID)
+	assert.Equal(t, "1.1.0", received.NextVersion)
+	assert.Equal(t, c.AxiomPath, received.AxiomPath)
+
+	start := time.Now()
+	results, err = guardian.runHooks(HookPreRestart, hookCtx)
+	assert.ErrorContains(t, err, "timeout")
+	assert.Less(t, time.Since(start), 3*time.Second)
+	assert.Equal(t, 1, len(results))
+
+	results, err = guardian.runHooks(HookPostRestart, hookCtx)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(results))
+	assert.NotEmpty(t, results[0].Error)
+	assert.FileExists(t, markPath)
+
+	results, err = guardian.runHooks(HookPostVerify, hookCtx)
+	assert.Nil(t, err)
+	assert.Nil(t, results)
+}
@@ -0,0 +1,54 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+
+	"github.com/sirupsen/logrus"
+)
+
+// httpListener serves a handler on a tcp address, it is shared by the http servers of guardian
+type httpListener struct {
+	name   string
+	addr   string
+	server *http.Server
+	logger logrus.FieldLogger
+}
+
+func newHTTPListener(name, addr string, handler http.Handler, logger logrus.FieldLogger) httpListener {
+	return httpListener{
+		name:   name,
+		addr:   addr,
+		server: &http.Server{Handler: handler},
+		logger: logger,
+	}
+}
+
+func (l *httpListener) Start() error {
+	listener, err := net.Listen("tcp", l.addr)
+	if err != nil {
+		return fmt.Errorf("listen %s address: %w", l.name, err)
+	}
+	// the configured port may be 0
+	l.addr = listener.Addr().String()
+
+	go func() {
+		if err := l.server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			l.logger.Errorf("%s server error: %s", l.name, err)
+		}
+	}()
+	l.logger.Infof("%s server listens on %s", l.name, l.addr)
+
+	return nil
+}
+
+// Addr returns the listening address after Start
+func (l *httpListener) Addr() string {
+	return l.addr
+}
+
+func (l *httpListener) Stop() error {
+	return l.server.Close()
+}
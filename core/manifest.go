@@ -0,0 +1,194 @@
+package core
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ManifestFileName is the name of the manifest at the root of a release bundle
+const ManifestFileName = "manifest.json"
+
+type FileRole string
+
+const (
+	// RoleBinary is an executable shipped with the release, e.g. axiom
+	RoleBinary FileRole = "binary"
+
+	// RoleScript is a helper script shipped with the release, e.g. version.sh
+	RoleScript FileRole = "script"
+
+	// RoleConfig is a config template shipped with the release
+	RoleConfig FileRole = "config"
+)
+
+// requiredLayout lists the files every release bundle must carry and the role they must have
+var requiredLayout = map[string]FileRole{
+	"axiom":      RoleBinary,
+	"version.sh": RoleScript,
+}
+
+type ManifestFile struct {
+	// Path is relative to the bundle root, using forward slashes
+	Path string `json:"path"`
+
+	// Digest is the hex encoded sha256 of the file content
+	Digest string `json:"digest"`
+
+	// Mode is the octal permission of the file, e.g. "0755"
+	Mode string `json:"mode"`
+
+	Role FileRole `json:"role"`
+}
+
+type Manifest struct {
+	Files []ManifestFile `json:"files"`
+}
+
+func LoadManifest(dir string) (*Manifest, error) {
+	data, err := os.ReadFile(filepath.Join(dir, ManifestFileName))
+	if err != nil {
+		return nil, fmt.Errorf("read manifest: %w", err)
+	}
+
+	manifest := &Manifest{}
+	if err := json.Unmarshal(data, manifest); err != nil {
+		return nil, fmt.Errorf("unmarshal manifest: %w", err)
+	}
+
+	return manifest, nil
+}
+
+// Validate checks the extracted release in dir against the manifest,
+// every file listed must exist with the same digest and mode, and no other file may exist.
+func (m *Manifest) Validate(dir string) error {
+	entries, err := m.entries()
+	if err != nil {
+		return err
+	}
+
+	var extra []string
+	seen := make(map[string]bool, len(entries))
+	err = filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if rel == "." || rel == ManifestFileName || d.IsDir() {
+			return nil
+		}
+
+		f, ok := entries[rel]
+		if !ok || !d.Type().IsRegular() {
+			extra = append(extra, rel)
+			return nil
+		}
+		seen[rel] = true
+
+		return f.check(p)
+	})
+	if err != nil {
+		return err
+	}
+
+	if len(extra) != 0 {
+		sort.Strings(extra)
+		return fmt.Errorf("release contains files not listed in manifest: %s", strings.Join(extra, ", "))
+	}
+
+	var missing []string
+	for p := range entries {
+		if !seen[p] {
+			missing = append(missing, p)
+		}
+	}
+	if len(missing) != 0 {
+		sort.Strings(missing)
+		return fmt.Errorf("release misses files listed in manifest: %s", strings.Join(missing, ", "))
+	}
+
+	return nil
+}
+
+// entries checks the paths and roles of the manifest and the required layout, keyed by the cleaned path
+func (m *Manifest) entries() (map[string]ManifestFile, error) {
+	entries := make(map[string]ManifestFile, len(m.Files))
+	for _, f := range m.Files {
+		p := filepath.ToSlash(filepath.Clean(filepath.FromSlash(f.Path)))
+		if p == "." || p == ManifestFileName || filepath.IsAbs(f.Path) || p == ".." || strings.HasPrefix(p, "../") {
+			return nil, fmt.Errorf("manifest contains invalid path %q", f.Path)
+		}
+		if _, ok := entries[p]; ok {
+			return nil, fmt.Errorf("manifest contains duplicate path %q", f.Path)
+		}
+		switch f.Role {
+		case RoleBinary, RoleScript, RoleConfig:
+		default:
+			return nil, fmt.Errorf("manifest file %s has unknown role %q", f.Path, f.Role)
+		}
+		entries[p] = f
+	}
+
+	for p, role := range requiredLayout {
+		f, ok := entries[p]
+		if !ok {
+			return nil, fmt.Errorf("manifest misses required file %s", p)
+		}
+		if f.Role != role {
+			return nil, fmt.Errorf("required file %s should have role %s, got %s", p, role, f.Role)
+		}
+	}
+
+	return entries, nil
+}
+
+func (f ManifestFile) check(p string) error {
+	info, err := os.Stat(p)
+	if err != nil {
+		return err
+	}
+
+	mode, err := strconv.ParseUint(f.Mode, 8, 32)
+	if err != nil {
+		return fmt.Errorf("manifest file %s has invalid mode %q", f.Path, f.Mode)
+	}
+	if info.Mode().Perm() != fs.FileMode(mode).Perm() {
+		return fmt.Errorf("file %s mode mismatch, manifest: %04o, actual: %04o", f.Path, fs.FileMode(mode).Perm(), info.Mode().Perm())
+	}
+
+	sum, err := fileSHA256(p)
+	if err != nil {
+		return err
+	}
+	if !strings.EqualFold(sum, f.Digest) {
+		return fmt.Errorf("file %s digest mismatch, manifest: %s, actual: %s", f.Path, f.Digest, sum)
+	}
+
+	return nil
+}
+
+func fileSHA256(p string) (string, error) {
+	f, err := os.Open(p)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("%x", h.Sum(nil)), nil
+}
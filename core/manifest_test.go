@@ -0,0 +1,115 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func writeRelease(t *testing.T, files map[string]string) (string, *Manifest) {
+	dir := t.TempDir()
+	manifest := &Manifest{}
+	for name, content := range files {
+		p := filepath.Join(dir, name)
+		assert.Nil(t, os.MkdirAll(filepath.Dir(p), 0755))
+		assert.Nil(t, os.WriteFile(p, []byte(content), 0755))
+		assert.Nil(t, os.Chmod(p, 0755))
+
+		digest, err := fileSHA256(p)
+		assert.Nil(t, err)
+
+		role := RoleConfig
+		if r, ok := requiredLayout[name]; ok {
+			role = r
+		}
+		manifest.Files = append(manifest.Files, ManifestFile{Path: name, Digest: digest, Mode: "0755", Role: role})
+	}
+
+	return dir, manifest
+}
+
+func saveManifest(t *testing.T, dir string, manifest *Manifest) {
+	data, err := json.Marshal(manifest)
+	assert.Nil(t, err)
+	assert.Nil(t, os.WriteFile(filepath.Join(dir, ManifestFileName), data, 0644))
+}
+
+func TestManifestValidate(t *testing.T) {
+	files := map[string]string{
+		"axiom":             "axiom binary",
+		"version.sh":        "echo 'Axiom version: v1.0.0'",
+		"config/axiom.toml": "[port]",
+	}
+
+	t.Run("valid", func(t *testing.T) {
+		dir, manifest := writeRelease(t, files)
+		saveManifest(t, dir, manifest)
+
+		loaded, err := LoadManifest(dir)
+		assert.Nil(t, err)
+		assert.Nil(t, loaded.Validate(dir))
+	})
+
+	t.Run("missing manifest", func(t *testing.T) {
+		dir, _ := writeRelease(t, files)
+
+		_, err := LoadManifest(dir)
+		assert.NotNil(t, err)
+	})
+
+	t.Run("missing file", func(t *testing.T) {
+		dir, manifest := writeRelease(t, files)
+		assert.Nil(t, os.Remove(filepath.Join(dir, "config/axiom.toml")))
+
+		err := manifest.Validate(dir)
+		assert.ErrorContains(t, err, "misses files")
+	})
+
+	t.Run("extra file", func(t *testing.T) {
+		dir, manifest := writeRelease(t, files)
+		assert.Nil(t, os.WriteFile(filepath.Join(dir, "evil.sh"), []byte("rm -rf /"), 0755))
+
+		err := manifest.Validate(dir)
+		assert.ErrorContains(t, err, "evil.sh")
+	})
+
+	t.Run("modified file", func(t *testing.T) {
+		dir, manifest := writeRelease(t, files)
+		assert.Nil(t, os.WriteFile(filepath.Join(dir, "axiom"), []byte("tampered"), 0755))
+
+		err := manifest.Validate(dir)
+		assert.ErrorContains(t, err, "digest mismatch")
+	})
+
+	t.Run("mode mismatch", func(t *testing.T) {
+		dir, manifest := writeRelease(t, files)
+		assert.Nil(t, os.Chmod(filepath.Join(dir, "version.sh"), 0777))
+
+		err := manifest.Validate(dir)
+		assert.ErrorContains(t, err, "mode mismatch")
+	})
+
+	t.Run("required layout", func(t *testing.T) {
+		dir, manifest := writeRelease(t, files)
+		for i := range manifest.Files {
+			if manifest.Files[i].Path == "axiom" {
+				manifest.Files[i].Role = RoleScript
+			}
+		}
+
+		err := manifest.Validate(dir)
+		assert.ErrorContains(t, err, fmt.Sprintf("should have role %s", RoleBinary))
+	})
+
+	t.Run("path escape", func(t *testing.T) {
+		dir, manifest := writeRelease(t, files)
+		manifest.Files = append(manifest.Files, ManifestFile{Path: "../axiom", Mode: "0755", Role: RoleBinary})
+
+		err := manifest.Validate(dir)
+		assert.ErrorContains(t, err, "invalid path")
+	})
+}
@@ -0,0 +1,154 @@
+package core
+
+import (
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/sirupsen/logrus"
+)
+
+const metricsNamespace = "guardian"
+
+const (
+	restartReasonUpgrade  = "upgrade"
+	restartReasonRollback = "rollback"
+)
+
+// guardianMetrics are always collected, they are only served if the metrics listener is enabled.
+// Every guardian has its own registry, so several guardians in one process do not conflict.
+type guardianMetrics struct {
+	registry *prometheus.Registry
+
+	lastBlock         prometheus.Gauge
+	logs              *prometheus.CounterVec
+	downloadBytes     *prometheus.CounterVec
+	downloadDuration  *prometheus.HistogramVec
+	downloadFailures  *prometheus.CounterVec
+	hashCheckFailures prometheus.Counter
+	restarts          *prometheus.CounterVec
+	version           *prometheus.GaugeVec
+}
+
+func newGuardianMetrics(g *Guardian) *guardianMetrics {
+	m := &guardianMetrics{
+		registry: prometheus.NewRegistry(),
+		lastBlock: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Name:      "last_processed_block",
+			Help:      "Block number of the last proposal log handled.",
+		}),
+		logs: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "proposal_logs_total",
+			Help:      "Proposal logs handled by proposal type.",
+		}, []string{"type"}),
+		downloadBytes: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "download_bytes_total",
+			Help:      "Bytes downloaded by mirror host.",
+		}, []string{"mirror"}),
+		downloadDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: metricsNamespace,
+			Name:      "download_duration_seconds",
+			Help:      "Duration of successful downloads by mirror host.",
+			Buckets:   prometheus.ExponentialBuckets(0.5, 2, 10),
+		}, []string{"mirror"}),
+		downloadFailures: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "download_failures_total",
+			Help:      "Failed download attempts by mirror host.",
+		}, []string{"mirror"}),
+		hashCheckFailures: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "hash_check_failures_total",
+			Help:      "Downloaded or patched files not matching the expected sha256.",
+		}),
+		restarts: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "restarts_total",
+			Help:      "Axiom restart attempts by reason and result.",
+		}, []string{"reason", "result"}),
+		version: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Name:      "axiom_version",
+			Help:      "Axiom version installed (current) and verified for the next restart (staged), the value is always 1.",
+		}, []string{"kind", "version"}),
+	}
+
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.lastBlock,
+		m.logs,
+		m.downloadBytes,
+		m.downloadDuration,
+		m.downloadFailures,
+		m.hashCheckFailures,
+		m.restarts,
+		m.version,
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Name:      "subscription_connected",
+			Help:      "1 if guardian is connected to axiom and subscribed to proposal logs, 0 otherwise.",
+		}, func() float64 {
+			// the same state as the readiness probe
+			if g.ConnState() == ConnConnected && g.probe.subscribed.Load() {
+				return 1
+			}
+			return 0
+		}),
+	)
+
+	return m
+}
+
+func (m *guardianMetrics) observeDownload(downloadUrl string, written int64, duration time.Duration, err error) {
+	mirror := mirrorLabel(downloadUrl)
+	m.downloadBytes.WithLabelValues(mirror).Add(float64(written))
+	if err != nil {
+		m.downloadFailures.WithLabelValues(mirror).Inc()
+		return
+	}
+	m.downloadDuration.WithLabelValues(mirror).Observe(duration.Seconds())
+}
+
+func (m *guardianMetrics) observeRestart(reason string, err error) {
+	result := "success"
+	if err != nil {
+		result = "failure"
+	}
+	m.restarts.WithLabelValues(reason, result).Inc()
+}
+
+// setVersion replaces the version of kind, an empty version removes it
+func (m *guardianMetrics) setVersion(kind, version string) {
+	m.version.DeletePartialMatch(prometheus.Labels{"kind": kind})
+	if version != "" {
+		m.version.WithLabelValues(kind, version).Set(1)
+	}
+}
+
+// mirrorLabel is the host of the download url, full urls would make too many series
+func mirrorLabel(downloadUrl string) string {
+	u, err := url.Parse(downloadUrl)
+	if err != nil || u.Host == "" {
+		return "unknown"
+	}
+	return u.Host
+}
+
+// MetricsServer serves the guardian metrics for prometheus to scrape
+type MetricsServer struct {
+	httpListener
+}
+
+func NewMetricsServer(addr string, registry *prometheus.Registry, logger logrus.FieldLogger) *MetricsServer {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.HandlerFor(registry, promhttp.HandlerOpts{}))
+
+	return &MetricsServer{newHTTPListener("metrics", addr, mux, logger)}
+}
@@ -0,0 +1,73 @@
+package core
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/axiomesh/guardian/repo"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMetrics(t *testing.T) {
+	c := repo.DefaultConfig(t.TempDir())
+	c.AxiomPath = t.TempDir()
+	c.Metrics.Enable = true
+	c.Metrics.ListenAddr = "127.0.0.1:0"
+	guardian, err := NewGuardian(context.Background(), c, StaticClientFactory(&MockClient{}))
+	assert.Nil(t, err)
+	assert.Nil(t, guardian.Start())
+	defer guardian.Stop()
+
+	mirror := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("release"))
+	}))
+	defer mirror.Close()
+	mirrorURL, err := url.Parse(mirror.URL)
+	assert.Nil(t, err)
+
+	_, err = guardian.fetch([]string{mirror.URL + "/axiom.tar.gz"}, "not the hash")
+	assert.NotNil(t, err)
+
+	guardian.metrics.setVersion("staged", "1.2.0")
+	guardian.metrics.setVersion("staged", "1.3.0")
+
+	scrape := func() string {
+		resp, err := http.Get("http://" + guardian.metricsServer.Addr() + "/metrics")
+		assert.Nil(t, err)
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		assert.Nil(t, err)
+		return string(body)
+	}
+	metrics := scrape()
+
+	// the history log of the mock client
+	assert.Contains(t, metrics, `guardian_proposal_logs_total{type="node_upgrade"} 1`)
+	assert.Contains(t, metrics, `guardian_subscription_connected 1`)
+	assert.Contains(t, metrics, `guardian_download_bytes_total{mirror="`+mirrorURL.Host+`"} 7`)
+	assert.Contains(t, metrics, `guardian_download_duration_seconds_count{mirror="`+mirrorURL.Host+`"} 1`)
+	assert.Contains(t, metrics, `guardian_hash_check_failures_total 1`)
+	assert.Contains(t, metrics, `guardian_axiom_version{kind="staged",version="1.3.0"} 1`)
+	assert.NotContains(t, metrics, `version="1.2.0"`)
+
+	guardian.metrics.setVersion("staged", "")
+	guardian.conn.set(ConnDisconnected)
+	metrics = scrape()
+	assert.NotContains(t, metrics, `kind="staged"`)
+	assert.Contains(t, metrics, `guardian_subscription_connected 0`)
+
+	// a failed subscription is reported while axiom is still connected
+	guardian.conn.set(ConnConnected)
+	guardian.probe.subscribed.Store(false)
+	assert.Contains(t, scrape(), `guardian_subscription_connected 0`)
+	assert.False(t, guardian.Readiness().Checks["subscription"].OK)
+}
+
+func TestMirrorLabel(t *testing.T) {
+	assert.Equal(t, "mirror.example.com:8080", mirrorLabel("https://mirror.example.com:8080/axiom.tar.gz"))
+	assert.Equal(t, "unknown", mirrorLabel("axiom.tar.gz"))
+}
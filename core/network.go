@@ -0,0 +1,40 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/axiomesh/guardian/repo"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+var ErrNetworkMismatch = errors.New("axiom is not on the expected network")
+
+// verifyNetwork checks client is connected to the configured chain with the expected node manager contract
+func verifyNetwork(ctx context.Context, client Client, network repo.Network) error {
+	if network.ChainID != 0 {
+		chainID, err := client.ChainID(ctx)
+		if err != nil {
+			return fmt.Errorf("get chain id: %w", err)
+		}
+		if !chainID.IsUint64() || chainID.Uint64() != network.ChainID {
+			return fmt.Errorf("%w: chain id is %s, expected %d", ErrNetworkMismatch, chainID, network.ChainID)
+		}
+	}
+
+	if network.NodeManagerCodeHash != "" {
+		code, err := client.CodeAt(ctx, common.HexToAddress(repo.NodeManagerContractAddr), nil)
+		if err != nil {
+			return fmt.Errorf("get node manager contract code: %w", err)
+		}
+		codeHash := crypto.Keccak256Hash(code)
+		if !strings.EqualFold(strings.TrimPrefix(network.NodeManagerCodeHash, "0x"), strings.TrimPrefix(codeHash.Hex(), "0x")) {
+			return fmt.Errorf("%w: node manager contract code hash is %s, expected %s", ErrNetworkMismatch, codeHash, network.NodeManagerCodeHash)
+		}
+	}
+
+	return nil
+}
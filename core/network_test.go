@@ -0,0 +1,32 @@
+package core
+
+import (
+	"context"
+	"testing"
+
+	"github.com/axiomesh/guardian/repo"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVerifyNetwork(t *testing.T) {
+	ctx := context.Background()
+	client := &MockClient{}
+	assert.Nil(t, verifyNetwork(ctx, client, repo.Network{}))
+
+	codeHash := crypto.Keccak256Hash([]byte("node manager")).Hex()
+	assert.Nil(t, verifyNetwork(ctx, client, repo.Network{ChainID: 1356, NodeManagerCodeHash: codeHash}))
+
+	err := verifyNetwork(ctx, client, repo.Network{ChainID: 1})
+	assert.ErrorIs(t, err, ErrNetworkMismatch)
+	assert.ErrorContains(t, err, "chain id is 1356, expected 1")
+
+	err = verifyNetwork(ctx, client, repo.Network{NodeManagerCodeHash: crypto.Keccak256Hash([]byte("other")).Hex()})
+	assert.ErrorIs(t, err, ErrNetworkMismatch)
+
+	// guardian refuses to run on another network
+	c := repo.DefaultConfig(t.TempDir())
+	c.Network.ChainID = 1
+	_, err = NewGuardian(ctx, c, StaticClientFactory(client))
+	assert.ErrorIs(t, err, ErrNetworkMismatch)
+}
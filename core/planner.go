@@ -0,0 +1,159 @@
+package core
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// addApprovedProposal records an approved upgrade proposal, proposals are kept ordered by id
+func (g *Guardian) addApprovedProposal(proposal *NodeProposal) {
+	for i, p := range g.approvedProposals {
+		if p.ID == proposal.ID {
+			g.approvedProposals[i] = proposal
+			return
+		}
+	}
+
+	g.approvedProposals = append(g.approvedProposals, proposal)
+	sort.Slice(g.approvedProposals, func(i, j int) bool {
+		return g.approvedProposals[i].ID < g.approvedProposals[j].ID
+	})
+}
+
+// planUpgradePath returns the approved proposals to apply in order to reach the newest version from currentVersion.
+//
+// Proposals already applied, incompatible with the version reached so far, denied by the local policy
+// or not newer than it are skipped.
+// A proposal without declared version ends the path, because the version after it is unknown until it is applied.
+func (g *Guardian) planUpgradePath(currentVersion *Version) []*NodeProposal {
+	lastID, applied := g.getLastUpgradeProposal()
+	policy := g.getPolicy()
+
+	var path []*NodeProposal
+	version := currentVersion
+	for _, proposal := range g.approvedProposals {
+		if applied && proposal.ID <= lastID {
+			continue
+		}
+
+		if err := g.checkCompatible(proposal, version); err != nil {
+			g.Logger.Errorf("skip upgrade proposal: %s", err)
+			continue
+		}
+
+		if err := policy.checkProposal(proposal); err != nil {
+			g.Logger.Warnf("skip upgrade proposal %d: %s", proposal.ID, err)
+			continue
+		}
+
+		if proposal.Version == "" {
+			path = append(path, proposal)
+			break
+		}
+
+		next, err := ParseVersion(proposal.Version)
+		if err != nil {
+			g.Logger.Errorf("skip upgrade proposal %d: %s", proposal.ID, err)
+			continue
+		}
+		if !proposal.Rollback && next.Compare(version) <= 0 {
+			g.Logger.Debugf("skip upgrade proposal %d: version %s is not newer than %s", proposal.ID, next, version)
+			continue
+		}
+
+		path = append(path, proposal)
+		version = next
+	}
+
+	return path
+}
+
+// persistApprovedProposal keeps the approved proposal until it is applied or passed by an applied one,
+// the checkpoint may move past its log long before, e.g. while upgrades are paused. Nothing is persisted in dry run mode.
+func (g *Guardian) persistApprovedProposal(proposal *NodeProposal) {
+	if g.dryRun != nil {
+		return
+	}
+	if lastID, applied := g.getLastUpgradeProposal(); applied && proposal.ID <= lastID {
+		return
+	}
+
+	data, err := json.Marshal(proposal)
+	if err != nil {
+		g.Logger.Errorf("marshal approved proposal %d error: %s", proposal.ID, err)
+		return
+	}
+	g.DB.Put([]byte(fmt.Sprintf("%s%d", approvedProposalKeyPrefix, proposal.ID)), data)
+}
+
+// restoreApprovedProposals queues the persisted proposals not applied yet
+func (g *Guardian) restoreApprovedProposals() error {
+	lastID, applied := g.getLastUpgradeProposal()
+	it := g.DB.Prefix([]byte(approvedProposalKeyPrefix))
+	for it.Next() {
+		proposal := &NodeProposal{}
+		if err := json.Unmarshal(it.Value(), proposal); err != nil {
+			return fmt.Errorf("unmarshal approved proposal %s: %w", it.Key(), err)
+		}
+		if applied && proposal.ID <= lastID {
+			continue
+		}
+		g.Logger.Infof("restore approved upgrade proposal %d", proposal.ID)
+		g.queue.push(proposal)
+	}
+
+	return nil
+}
+
+// recordUpgradeProposal records id as the last applied upgrade proposal, only in memory in dry run mode.
+// The persisted proposals up to id are dropped, the planner never applies them.
+func (g *Guardian) recordUpgradeProposal(id uint64) {
+	if g.dryRun != nil {
+		g.dryRun.lastProposalID = id
+		g.dryRun.applied = true
+		return
+	}
+
+	data := make([]byte, 8)
+	binary.BigEndian.PutUint64(data, id)
+	batch := g.DB.NewBatch()
+	batch.Put([]byte(lastUpgradeProposalKey), data)
+	it := g.DB.Prefix([]byte(approvedProposalKeyPrefix))
+	for it.Next() {
+		proposalID, err := strconv.ParseUint(strings.TrimPrefix(string(it.Key()), approvedProposalKeyPrefix), 10, 64)
+		if err == nil && proposalID <= id {
+			batch.Delete(append([]byte(nil), it.Key()...))
+		}
+	}
+	batch.Commit()
+}
+
+func (g *Guardian) getLastUpgradeProposal() (uint64, bool) {
+	if g.dryRun != nil && g.dryRun.applied {
+		return g.dryRun.lastProposalID, true
+	}
+
+	data := g.DB.Get([]byte(lastUpgradeProposalKey))
+	if len(data) != 8 {
+		return 0, false
+	}
+
+	return binary.BigEndian.Uint64(data), true
+}
+
+func describeUpgradePath(path []*NodeProposal) string {
+	hops := make([]string, 0, len(path))
+	for _, proposal := range path {
+		version := proposal.Version
+		if version == "" {
+			version = "unknown version"
+		}
+		hops = append(hops, fmt.Sprintf("proposal %d (%s)", proposal.ID, version))
+	}
+
+	return strings.Join(hops, " -> ")
+}
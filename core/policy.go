@@ -0,0 +1,167 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/axiomesh/guardian/repo"
+)
+
+var ErrPolicyDenied = errors.New("denied by local policy")
+
+// Policy is the parsed local upgrade policy
+type Policy struct {
+	pinned         *Version
+	deniedVersions []*Version
+	deniedDigests  map[string]bool
+	allowedRanges  []*Constraint
+}
+
+// NewPolicy parses the policy of config, an invalid version, digest or range is an error
+func NewPolicy(config repo.Policy) (*Policy, error) {
+	p := &Policy{deniedDigests: make(map[string]bool)}
+
+	if config.PinnedVersion != "" {
+		pinned, err := ParseVersion(config.PinnedVersion)
+		if err != nil {
+			return nil, fmt.Errorf("pinned version: %w", err)
+		}
+		p.pinned = pinned
+	}
+
+	for _, s := range config.DeniedVersions {
+		v, err := ParseVersion(s)
+		if err != nil {
+			return nil, fmt.Errorf("denied version: %w", err)
+		}
+		p.deniedVersions = append(p.deniedVersions, v)
+	}
+
+	for _, s := range config.DeniedDigests {
+		digest, err := normalizeDigest(s)
+		if err != nil {
+			return nil, err
+		}
+		p.deniedDigests[digest] = true
+	}
+
+	for _, s := range config.AllowedRanges {
+		c, err := ParseConstraint(s)
+		if err != nil {
+			return nil, fmt.Errorf("allowed range: %w", err)
+		}
+		p.allowedRanges = append(p.allowedRanges, c)
+	}
+
+	return p, nil
+}
+
+// IsDigest reports whether s looks like a sha256 hex digest instead of a version
+func IsDigest(s string) bool {
+	_, err := normalizeDigest(s)
+	return err == nil
+}
+
+func normalizeDigest(s string) (string, error) {
+	digest := strings.ToLower(strings.TrimPrefix(s, "0x"))
+	if len(digest) != 64 || strings.Trim(digest, "0123456789abcdef") != "" {
+		return "", fmt.Errorf("invalid sha256 digest %q", s)
+	}
+	return digest, nil
+}
+
+// CheckVersion checks the upgrade to version is allowed
+func (p *Policy) CheckVersion(version *Version) error {
+	if p.pinned != nil && version.Compare(p.pinned) != 0 {
+		return fmt.Errorf("%w: version is pinned to %s", ErrPolicyDenied, p.pinned)
+	}
+
+	for _, denied := range p.deniedVersions {
+		if version.Compare(denied) == 0 {
+			return fmt.Errorf("%w: version %s is denied", ErrPolicyDenied, version)
+		}
+	}
+
+	if len(p.allowedRanges) == 0 {
+		return nil
+	}
+	for _, allowed := range p.allowedRanges {
+		if allowed.Check(version) {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: version %s is out of the allowed ranges", ErrPolicyDenied, version)
+}
+
+// CheckDigests checks none of the release digests of proposal is denied
+func (p *Policy) CheckDigests(proposal *NodeProposal) error {
+	digests := []string{proposal.CheckHash}
+	if proposal.Delta != nil {
+		digests = append(digests, proposal.Delta.CheckHash, proposal.Delta.TargetHash)
+	}
+
+	for _, digest := range digests {
+		if err := p.CheckDigest(digest); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// CheckDigest checks the sha256 digest of an artifact or of the extracted axiom binary is not denied
+func (p *Policy) CheckDigest(digest string) error {
+	if digest == "" {
+		return nil
+	}
+	if p.deniedDigests[strings.ToLower(strings.TrimPrefix(digest, "0x"))] {
+		return fmt.Errorf("%w: digest %s is denied", ErrPolicyDenied, digest)
+	}
+	return nil
+}
+
+// checkProposal checks what is known of proposal before download, the release version is checked again after it
+func (p *Policy) checkProposal(proposal *NodeProposal) error {
+	if err := p.CheckDigests(proposal); err != nil {
+		return err
+	}
+
+	if proposal.Version == "" {
+		return nil
+	}
+	version, err := ParseVersion(proposal.Version)
+	if err != nil {
+		return err
+	}
+	return p.CheckVersion(version)
+}
+
+func (g *Guardian) getPolicy() *Policy {
+	g.policyLock.RLock()
+	defer g.policyLock.RUnlock()
+
+	return g.policy
+}
+
+// ReloadPolicy reads the policy from the config file and applies it to the next upgrade,
+// the running policy is kept if the new one is invalid
+func (g *Guardian) ReloadPolicy() error {
+	r, err := repo.Load(g.Config.RepoRoot)
+	if err != nil {
+		return fmt.Errorf("load config: %w", err)
+	}
+
+	policy, err := NewPolicy(r.Config.Policy)
+	if err != nil {
+		return err
+	}
+
+	g.policyLock.Lock()
+	g.policy = policy
+	g.Config.Policy = r.Config.Policy
+	g.policyLock.Unlock()
+
+	g.Logger.Infof("policy reloaded: %+v", r.Config.Policy)
+	return nil
+}
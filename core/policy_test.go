@@ -0,0 +1,115 @@
+package core
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	"github.com/axiomesh/guardian/repo"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPolicy(t *testing.T) {
+	_, err := NewPolicy(repo.Policy{PinnedVersion: "latest"})
+	assert.ErrorIs(t, err, ErrInvalidVersion)
+	_, err = NewPolicy(repo.Policy{DeniedDigests: []string{"abc"}})
+	assert.ErrorContains(t, err, "invalid sha256 digest")
+	_, err = NewPolicy(repo.Policy{AllowedRanges: []string{">=x"}})
+	assert.ErrorIs(t, err, ErrInvalidConstraint)
+
+	digest := strings.Repeat("ab", 32)
+	p, err := NewPolicy(repo.Policy{
+		DeniedVersions: []string{"1.3.0"},
+		DeniedDigests:  []string{"0x" + strings.ToUpper(digest)},
+		AllowedRanges:  []string{">=1.0.0 <1.5.0", ">=2.0.0 <2.1.0"},
+	})
+	assert.Nil(t, err)
+	assert.Nil(t, p.CheckVersion(&Version{Major: 1, Minor: 2}))
+	assert.Nil(t, p.CheckVersion(&Version{Major: 2}))
+	assert.ErrorIs(t, p.CheckVersion(&Version{Major: 1, Minor: 3}), ErrPolicyDenied)
+	assert.ErrorContains(t, p.CheckVersion(&Version{Major: 1, Minor: 5}), "out of the allowed ranges")
+
+	assert.Nil(t, p.CheckDigests(&NodeProposal{CheckHash: strings.Repeat("cd", 32)}))
+	assert.ErrorIs(t, p.CheckDigests(&NodeProposal{CheckHash: digest}), ErrPolicyDenied)
+	assert.ErrorIs(t, p.CheckDigests(&NodeProposal{Delta: &DeltaArtifact{TargetHash: digest}}), ErrPolicyDenied)
+
+	p, err = NewPolicy(repo.Policy{PinnedVersion: "1.2.0"})
+	assert.Nil(t, err)
+	assert.Nil(t, p.CheckVersion(&Version{Major: 1, Minor: 2}))
+	assert.ErrorContains(t, p.CheckVersion(&Version{Major: 1, Minor: 4}), "pinned to 1.2.0")
+}
+
+func TestReloadPolicy(t *testing.T) {
+	r, err := repo.Load(t.TempDir())
+	assert.Nil(t, err)
+	guardian, err := NewGuardian(context.Background(), r.Config, StaticClientFactory(&MockClient{}))
+	assert.Nil(t, err)
+
+	guardian.addApprovedProposal(&NodeProposal{BaseProposal: BaseProposal{ID: 1}, Version: "1.1.0"})
+	guardian.addApprovedProposal(&NodeProposal{BaseProposal: BaseProposal{ID: 2}, Version: "1.2.0"})
+	assert.Equal(t, 2, len(guardian.planUpgradePath(&Version{Major: 1})))
+
+	// edited by another process
+	edited, err := repo.Load(r.Config.RepoRoot)
+	assert.Nil(t, err)
+	edited.Config.Policy.PinnedVersion = "1.1.0"
+	assert.Nil(t, edited.Flush())
+
+	assert.Nil(t, guardian.ReloadPolicy())
+	path := guardian.planUpgradePath(&Version{Major: 1})
+	assert.Equal(t, 1, len(path))
+	assert.Equal(t, uint64(1), path[0].ID)
+
+	// an invalid policy is not applied
+	edited.Config.Policy.PinnedVersion = "latest"
+	assert.Nil(t, edited.Flush())
+	assert.ErrorIs(t, guardian.ReloadPolicy(), ErrInvalidVersion)
+	assert.Equal(t, 1, len(guardian.planUpgradePath(&Version{Major: 1})))
+}
+
+func TestStageReleaseRefused(t *testing.T) {
+	c := repo.DefaultConfig(t.TempDir())
+	c.Policy.DeniedVersions = []string{"1.3.0"}
+	guardian, err := NewGuardian(context.Background(), c, StaticClientFactory(&MockClient{}))
+	assert.Nil(t, err)
+
+	stage := func(version string, proposal *NodeProposal) (string, error) {
+		release, _ := writeRelease(t, map[string]string{
+			"axiom":      "binary",
+			"version.sh": "echo 'Axiom version: " + version + "'",
+		})
+		guardian.nextUpgradeProposal = proposal
+		_, err := guardian.stageRelease(release, &Version{Major: 1})
+		return release, err
+	}
+
+	release, err := stage("1.3.0", &NodeProposal{})
+	assert.ErrorIs(t, err, ErrPolicyDenied)
+	assert.NoDirExists(t, release)
+	assert.Nil(t, guardian.nextUpgradeProposal)
+
+	release, err = stage("1.2.0", &NodeProposal{Version: "1.4.0"})
+	assert.ErrorIs(t, err, ErrPolicyDenied)
+	assert.ErrorContains(t, err, "not match proposal version 1.4.0")
+	assert.NoDirExists(t, release)
+
+	// the extracted binary is denied though the archive is not
+	binaryDigest := sha256.Sum256([]byte("denied"))
+	guardian.policy, err = NewPolicy(repo.Policy{DeniedDigests: []string{hex.EncodeToString(binaryDigest[:])}})
+	assert.Nil(t, err)
+	release, _ = writeRelease(t, map[string]string{
+		"axiom":      "denied",
+		"version.sh": "echo 'Axiom version: 1.2.0'",
+	})
+	guardian.nextUpgradeProposal = &NodeProposal{}
+	_, err = guardian.stageRelease(release, &Version{Major: 1})
+	assert.ErrorIs(t, err, ErrPolicyDenied)
+	assert.NoDirExists(t, release)
+
+	release, err = stage("1.2.0", &NodeProposal{Version: "1.2.0"})
+	assert.Nil(t, err)
+	assert.DirExists(t, release)
+	assert.Equal(t, "1.2.0", guardian.nextUpgradeVersion)
+}
@@ -0,0 +1,140 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// loopHeartbeatInterval is how often the idle event loop proves it is alive
+	loopHeartbeatInterval = 10 * time.Second
+
+	probeKey = "probe"
+)
+
+// probeState is updated by the event loop and the connection, the probes only read it
+type probeState struct {
+	// loopBeat is the unix nano time the event loop or the reconnect loop last ran
+	loopBeat atomic.Int64
+	// backfilled is set once the history logs are fetched after the last connect
+	backfilled atomic.Bool
+	// subscribed is set while the log subscription has not failed
+	subscribed atomic.Bool
+}
+
+func (p *probeState) beat() {
+	p.loopBeat.Store(time.Now().UnixNano())
+}
+
+// ProbeResult is the reply of the liveness and readiness probes
+type ProbeResult struct {
+	OK     bool
+	Checks map[string]ProbeCheck
+}
+
+type ProbeCheck struct {
+	OK    bool
+	Error string `json:",omitempty"`
+}
+
+func newProbeResult(checks map[string]error) *ProbeResult {
+	result := &ProbeResult{OK: true, Checks: make(map[string]ProbeCheck, len(checks))}
+	for name, err := range checks {
+		check := ProbeCheck{OK: err == nil}
+		if err != nil {
+			check.Error = err.Error()
+			result.OK = false
+		}
+		result.Checks[name] = check
+	}
+	return result
+}
+
+// Liveness reports whether guardian is stuck and should be restarted: the event loop runs and the db is writable
+func (g *Guardian) Liveness() *ProbeResult {
+	return newProbeResult(map[string]error{
+		"event_loop": g.checkEventLoop(),
+		"db":         g.checkDBWritable(),
+	})
+}
+
+// Readiness reports whether guardian follows the chain: connected to axiom, history logs fetched and logs subscribed
+func (g *Guardian) Readiness() *ProbeResult {
+	checks := map[string]error{
+		"rpc":          nil,
+		"backfill":     nil,
+		"subscription": nil,
+	}
+	if state := g.ConnState(); state != ConnConnected {
+		checks["rpc"] = fmt.Errorf("rpc is %s", state)
+	}
+	if !g.probe.backfilled.Load() {
+		checks["backfill"] = errors.New("history logs are not fetched yet")
+	}
+	if !g.probe.subscribed.Load() {
+		checks["subscription"] = errors.New("logs are not subscribed")
+	}
+	return newProbeResult(checks)
+}
+
+func (g *Guardian) checkEventLoop() error {
+	last := g.probe.loopBeat.Load()
+	if last == 0 {
+		return errors.New("event loop is not started")
+	}
+	if since := time.Since(time.Unix(0, last)); since > g.Config.Probe.LoopTimeout {
+		return fmt.Errorf("event loop not run for %s", since.Truncate(time.Second))
+	}
+	return nil
+}
+
+// checkDBWritable writes and reads back a probe key, the db panics on io errors
+func (g *Guardian) checkDBWritable() (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("db is not writable: %v", r)
+		}
+	}()
+
+	value := []byte(strconv.FormatInt(time.Now().UnixNano(), 10))
+	g.DB.Put([]byte(probeKey), value)
+	if string(g.DB.Get([]byte(probeKey))) != string(value) {
+		return errors.New("db returns a different value than written")
+	}
+	return nil
+}
+
+// ProbeServer serves the liveness and readiness probes
+type ProbeServer struct {
+	httpListener
+}
+
+func NewProbeServer(guardian *Guardian, addr string, logger logrus.FieldLogger) *ProbeServer {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", handleProbe(guardian.Liveness))
+	mux.HandleFunc("/readyz", handleProbe(guardian.Readiness))
+
+	return &ProbeServer{newHTTPListener("probe", addr, mux, logger)}
+}
+
+func handleProbe(probe func() *ProbeResult) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("method %s not allowed", r.Method))
+			return
+		}
+
+		result := probe()
+		status := http.StatusOK
+		if !result.OK {
+			status = http.StatusServiceUnavailable
+		}
+		writeJSON(w, status, result)
+	}
+}
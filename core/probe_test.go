@@ -0,0 +1,71 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/axiomesh/guardian/repo"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProbe(t *testing.T) {
+	c := repo.DefaultConfig(t.TempDir())
+	c.AxiomPath = t.TempDir()
+	c.Probe.Enable = true
+	c.Probe.ListenAddr = "127.0.0.1:0"
+	guardian, err := NewGuardian(context.Background(), c, StaticClientFactory(&MockClient{}))
+	assert.Nil(t, err)
+
+	assert.False(t, guardian.Liveness().OK)
+	readiness := guardian.Readiness()
+	assert.False(t, readiness.OK)
+	assert.True(t, readiness.Checks["rpc"].OK)
+	assert.Equal(t, "history logs are not fetched yet", readiness.Checks["backfill"].Error)
+
+	assert.Nil(t, guardian.Start())
+	defer guardian.Stop()
+
+	probe := func(path string) (int, *ProbeResult) {
+		resp, err := http.Get("http://" + guardian.probeServer.Addr() + path)
+		assert.Nil(t, err)
+		defer resp.Body.Close()
+		result := &ProbeResult{}
+		assert.Nil(t, json.NewDecoder(resp.Body).Decode(result))
+		return resp.StatusCode, result
+	}
+
+	assert.Eventually(t, func() bool {
+		status, _ := probe("/healthz")
+		return status == http.StatusOK
+	}, time.Second, 10*time.Millisecond)
+	status, result := probe("/readyz")
+	assert.Equal(t, http.StatusOK, status)
+	assert.True(t, result.OK)
+	assert.Len(t, result.Checks, 3)
+
+	guardian.conn.set(ConnConnecting)
+	status, result = probe("/readyz")
+	assert.Equal(t, http.StatusServiceUnavailable, status)
+	assert.Equal(t, "rpc is connecting", result.Checks["rpc"].Error)
+	assert.True(t, result.Checks["subscription"].OK)
+
+	// a stuck event loop
+	guardian.probe.loopBeat.Store(time.Now().Add(-time.Hour).UnixNano())
+	status, result = probe("/healthz")
+	assert.Equal(t, http.StatusServiceUnavailable, status)
+	assert.False(t, result.Checks["event_loop"].OK)
+	assert.True(t, result.Checks["db"].OK)
+}
+
+func TestCheckDBWritable(t *testing.T) {
+	c := repo.DefaultConfig(t.TempDir())
+	guardian, err := NewGuardian(context.Background(), c, StaticClientFactory(&MockClient{}))
+	assert.Nil(t, err)
+
+	assert.Nil(t, guardian.checkDBWritable())
+	assert.Nil(t, guardian.DB.Close())
+	assert.ErrorContains(t, guardian.checkDBWritable(), "db is not writable")
+}
@@ -0,0 +1,189 @@
+package core
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/axiomesh/guardian/repo"
+)
+
+const (
+	// ReleasesDirName is the directory under the axiom path keeps every installed release by version
+	ReleasesDirName = "releases"
+
+	// CurrentLinkName is the symlink under the axiom path points to the live release
+	CurrentLinkName = "current"
+)
+
+// liveBinaryPath returns the path of the axiom binary run by the node
+func liveBinaryPath(config *repo.Config) string {
+	if config.Release.Managed {
+		return filepath.Join(config.AxiomPath, CurrentLinkName, "axiom")
+	}
+
+	return filepath.Join(config.AxiomPath, "axiom")
+}
+
+// installedBinaryPath returns the path of the installed axiom binary,
+// a managed layout not switched yet still runs the binary under the axiom path
+func installedBinaryPath(config *repo.Config) string {
+	if config.Release.Managed {
+		if _, err := os.Stat(filepath.Join(config.AxiomPath, CurrentLinkName)); err == nil {
+			return liveBinaryPath(config)
+		}
+	}
+
+	return filepath.Join(config.AxiomPath, "axiom")
+}
+
+func (g *Guardian) installedBinaryPath() string {
+	return installedBinaryPath(g.Config)
+}
+
+// binaryTarget is where the restarters owning the live binary install the new one,
+// it is resolved on every use as the managed layout may be switched after start
+type binaryTarget struct {
+	config *repo.Config
+}
+
+// path returns the binary installed now
+func (t binaryTarget) path() string {
+	return installedBinaryPath(t.config)
+}
+
+// activated returns the binary installed once a release is activated, dry run describes the restart after it
+func (t binaryTarget) activated() string {
+	return liveBinaryPath(t.config)
+}
+
+// activate makes the release in releasePath live and returns the binary path to restart axiom with.
+//
+// In the managed layout the release is installed as releases/<version> and the current symlink is switched to it,
+// otherwise the binary in releasePath is handed to the restarter as it is.
+func (g *Guardian) activate(releasePath string, version *Version) (string, error) {
+	if !g.Config.Release.Managed {
+		return filepath.Join(releasePath, "axiom"), nil
+	}
+
+	versionPath, err := installRelease(g.Config.AxiomPath, releasePath, version)
+	if err != nil {
+		return "", err
+	}
+
+	if err := switchCurrent(g.Config.AxiomPath, versionPath); err != nil {
+		return "", err
+	}
+	g.Logger.Infof("current release switched to %s", versionPath)
+
+	return liveBinaryPath(g.Config), nil
+}
+
+// installRelease copies the release in releasePath to <axiomPath>/releases/<version> and returns the new path
+func installRelease(axiomPath, releasePath string, version *Version) (string, error) {
+	releasesPath := filepath.Join(axiomPath, ReleasesDirName)
+	versionPath := filepath.Join(releasesPath, version.String())
+	if releasePath == versionPath {
+		return versionPath, nil
+	}
+
+	if err := os.MkdirAll(releasesPath, 0755); err != nil {
+		return "", err
+	}
+
+	// copy to a temporary directory first, so releases/<version> is always complete
+	tmpPath, err := os.MkdirTemp(releasesPath, fmt.Sprintf(".%s-", version))
+	if err != nil {
+		return "", err
+	}
+	if err := copyDir(releasePath, tmpPath); err != nil {
+		_ = os.RemoveAll(tmpPath)
+		return "", fmt.Errorf("copy release: %w", err)
+	}
+
+	// a reinstalled version may be the one current points to, it is moved aside rather than
+	// removed until the new copy is in place, a directory can not be renamed over a non empty one
+	oldPath := ""
+	if _, err := os.Lstat(versionPath); err == nil {
+		oldPath = tmpPath + ".old"
+		if err := os.Rename(versionPath, oldPath); err != nil {
+			_ = os.RemoveAll(tmpPath)
+			return "", err
+		}
+	}
+	if err := os.Rename(tmpPath, versionPath); err != nil {
+		if oldPath != "" {
+			_ = os.Rename(oldPath, versionPath)
+		}
+		_ = os.RemoveAll(tmpPath)
+		return "", err
+	}
+	if oldPath != "" {
+		_ = os.RemoveAll(oldPath)
+	}
+
+	return versionPath, nil
+}
+
+// switchCurrent points the current symlink to versionPath, the new link is renamed over the old one atomically
+func switchCurrent(axiomPath, versionPath string) error {
+	target, err := filepath.Rel(axiomPath, versionPath)
+	if err != nil {
+		return err
+	}
+
+	tmpLink := filepath.Join(axiomPath, fmt.Sprintf(".%s-%d", CurrentLinkName, time.Now().UnixNano()))
+	if err := os.Symlink(target, tmpLink); err != nil {
+		return err
+	}
+
+	if err := os.Rename(tmpLink, filepath.Join(axiomPath, CurrentLinkName)); err != nil {
+		_ = os.Remove(tmpLink)
+		return fmt.Errorf("switch current release: %w", err)
+	}
+
+	return nil
+}
+
+// currentRelease returns the path of the release the current symlink points to, empty if not switched yet
+func (g *Guardian) currentRelease() (string, error) {
+	target, err := os.Readlink(filepath.Join(g.Config.AxiomPath, CurrentLinkName))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", nil
+		}
+		return "", err
+	}
+
+	if !filepath.IsAbs(target) {
+		target = filepath.Join(g.Config.AxiomPath, target)
+	}
+
+	return target, nil
+}
+
+func copyDir(src, dst string) error {
+	return filepath.WalkDir(src, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(src, p)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+
+		if d.IsDir() {
+			info, err := d.Info()
+			if err != nil {
+				return err
+			}
+			return os.MkdirAll(target, info.Mode().Perm())
+		}
+
+		return copyFile(p, target)
+	})
+}
@@ -0,0 +1,81 @@
+package core
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/axiomesh/guardian/repo"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestActivateManagedRelease(t *testing.T) {
+	c := repo.DefaultConfig(t.TempDir())
+	c.AxiomPath = t.TempDir()
+	c.Release.Managed = true
+	guardian, err := NewGuardian(context.Background(), c, StaticClientFactory(&MockClient{}))
+	assert.Nil(t, err)
+
+	// not switched yet, legacy binary is installed
+	assert.Equal(t, filepath.Join(c.AxiomPath, "axiom"), guardian.installedBinaryPath())
+	current, err := guardian.currentRelease()
+	assert.Nil(t, err)
+	assert.Empty(t, current)
+
+	v1, _ := writeRelease(t, map[string]string{"axiom": "axiom v1.0.0", "version.sh": "echo 'Axiom version: v1.0.0'"})
+	binaryPath, err := guardian.activate(v1, &Version{Major: 1})
+	assert.Nil(t, err)
+	assert.Equal(t, filepath.Join(c.AxiomPath, CurrentLinkName, "axiom"), binaryPath)
+	assert.Equal(t, binaryPath, guardian.installedBinaryPath())
+
+	v2, _ := writeRelease(t, map[string]string{"axiom": "axiom v1.1.0", "version.sh": "echo 'Axiom version: v1.1.0'"})
+	_, err = guardian.activate(v2, &Version{Major: 1, Minor: 1})
+	assert.Nil(t, err)
+
+	target, err := os.Readlink(filepath.Join(c.AxiomPath, CurrentLinkName))
+	assert.Nil(t, err)
+	assert.Equal(t, filepath.Join(ReleasesDirName, "1.1.0"), target)
+	data, err := os.ReadFile(binaryPath)
+	assert.Nil(t, err)
+	assert.Equal(t, "axiom v1.1.0", string(data))
+
+	// previous release stays on disk and is used for rollback
+	previous := filepath.Join(c.AxiomPath, ReleasesDirName, "1.0.0")
+	assert.FileExists(t, filepath.Join(previous, "axiom"))
+	backupPath, err := guardian.backupInstalled(&Version{Major: 1, Minor: 1})
+	assert.Nil(t, err)
+	assert.Equal(t, filepath.Join(c.AxiomPath, ReleasesDirName, "1.1.0"), backupPath)
+
+	_, err = guardian.activate(previous, &Version{Major: 1})
+	assert.Nil(t, err)
+	current, err = guardian.currentRelease()
+	assert.Nil(t, err)
+	assert.Equal(t, previous, current)
+	data, err = os.ReadFile(binaryPath)
+	assert.Nil(t, err)
+	assert.Equal(t, "axiom v1.0.0", string(data))
+
+	// reinstalling the live version replaces it in place
+	v1, _ = writeRelease(t, map[string]string{"axiom": "axiom v1.0.0 rebuilt", "version.sh": "echo 'Axiom version: v1.0.0'"})
+	_, err = guardian.activate(v1, &Version{Major: 1})
+	assert.Nil(t, err)
+	data, err = os.ReadFile(binaryPath)
+	assert.Nil(t, err)
+	assert.Equal(t, "axiom v1.0.0 rebuilt", string(data))
+	entries, err := os.ReadDir(filepath.Join(c.AxiomPath, ReleasesDirName))
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(entries))
+}
+
+func TestActivateLegacyRelease(t *testing.T) {
+	c := repo.DefaultConfig(t.TempDir())
+	c.AxiomPath = t.TempDir()
+	guardian, err := NewGuardian(context.Background(), c, StaticClientFactory(&MockClient{}))
+	assert.Nil(t, err)
+
+	binaryPath, err := guardian.activate("/tmp/axiom-1", &Version{Major: 1})
+	assert.Nil(t, err)
+	assert.Equal(t, "/tmp/axiom-1/axiom", binaryPath)
+	assert.NoDirExists(t, filepath.Join(c.AxiomPath, ReleasesDirName))
+}
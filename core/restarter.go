@@ -0,0 +1,227 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/axiomesh/guardian/repo"
+	"github.com/sirupsen/logrus"
+)
+
+// Restarter replaces the running axiom with a new binary
+type Restarter interface {
+	// Restart makes axiom run the binary at binaryPath
+	Restart(ctx context.Context, binaryPath string) error
+
+	// Describe returns what Restart would do with binaryPath, used by dry run
+	Describe(binaryPath string) string
+}
+
+// restarterLifecycle is implemented by restarters which own the axiom process,
+// they are started and stopped together with guardian
+type restarterLifecycle interface {
+	Start() error
+	Stop() error
+}
+
+var (
+	_ Restarter = (*ScriptRestarter)(nil)
+	_ Restarter = (*SystemdRestarter)(nil)
+	_ Restarter = (*DockerRestarter)(nil)
+	_ Restarter = (*NoopRestarter)(nil)
+)
+
+// NewRestarter creates the restarter of the configured restart mode
+func NewRestarter(config *repo.Config, logger logrus.FieldLogger) (Restarter, error) {
+	switch config.Restart.Mode {
+	case repo.RestartModeScript, "":
+		return &ScriptRestarter{axiomPath: config.AxiomPath, logger: logger}, nil
+	case repo.RestartModeSupervisor:
+		return NewSupervisor(config, logger), nil
+	case repo.RestartModeSystemd:
+		return &SystemdRestarter{
+			binary:    binaryTarget{config: config},
+			unit:      config.Restart.Systemd.Unit,
+			systemctl: config.Restart.Systemd.Systemctl,
+			logger:    logger,
+		}, nil
+	case repo.RestartModeDocker:
+		return NewDockerRestarter(config, logger), nil
+	case repo.RestartModeNoop:
+		return &NoopRestarter{logger: logger}, nil
+	default:
+		return nil, fmt.Errorf("unknown restart mode %q", config.Restart.Mode)
+	}
+}
+
+// ScriptRestarter runs restart.sh under the axiom path with the new binary path as argument
+type ScriptRestarter struct {
+	axiomPath string
+	logger    logrus.FieldLogger
+}
+
+func (r *ScriptRestarter) Restart(ctx context.Context, binaryPath string) error {
+	// check restart.sh existence
+	if _, err := os.Stat(filepath.Join(r.axiomPath, "restart.sh")); err != nil {
+		return err
+	}
+
+	// execute restart shell
+	execCmd := r.command(binaryPath)
+
+	r.logger.Debugf("exec restart command: %s", execCmd)
+
+	cmd := exec.CommandContext(ctx, "bash", "-c", execCmd)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("exec restart.sh: %w, output: %s", err, out)
+	}
+
+	return nil
+}
+
+func (r *ScriptRestarter) Describe(binaryPath string) string {
+	return fmt.Sprintf("exec: %s", r.command(binaryPath))
+}
+
+func (r *ScriptRestarter) command(binaryPath string) string {
+	return fmt.Sprintf("cd %s && bash restart.sh %s", r.axiomPath, binaryPath)
+}
+
+// SystemdRestarter installs the new binary as the live binary and restarts the axiom systemd unit
+type SystemdRestarter struct {
+	binary    binaryTarget
+	unit      string
+	systemctl string
+	logger    logrus.FieldLogger
+}
+
+func (r *SystemdRestarter) Restart(ctx context.Context, binaryPath string) error {
+	if err := installBinary(binaryPath, r.binary.path()); err != nil {
+		return err
+	}
+
+	r.logger.Debugf("exec restart command: %s restart %s", r.systemctl, r.unit)
+
+	cmd := exec.CommandContext(ctx, r.systemctl, "restart", r.unit)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("systemctl restart %s: %w, output: %s", r.unit, err, out)
+	}
+
+	return nil
+}
+
+func (r *SystemdRestarter) Describe(binaryPath string) string {
+	return fmt.Sprintf("%sexec: %s restart %s", describeInstall(binaryPath, r.binary.activated()), r.systemctl, r.unit)
+}
+
+// DockerRestarter installs the new binary as the live binary, the axiom path should be mounted into the axiom container,
+// and restarts the container by the docker engine api
+type DockerRestarter struct {
+	binary      binaryTarget
+	container   string
+	stopTimeout time.Duration
+	client      *http.Client
+	logger      logrus.FieldLogger
+}
+
+func NewDockerRestarter(config *repo.Config, logger logrus.FieldLogger) *DockerRestarter {
+	return &DockerRestarter{
+		binary:      binaryTarget{config: config},
+		container:   config.Restart.Docker.Container,
+		stopTimeout: config.Restart.Docker.StopTimeout,
+		client:      newUnixHTTPClient(config.Restart.Docker.Socket),
+		logger:      logger,
+	}
+}
+
+func (r *DockerRestarter) Restart(ctx context.Context, binaryPath string) error {
+	if err := installBinary(binaryPath, r.binary.path()); err != nil {
+		return err
+	}
+
+	endpoint := r.endpoint()
+	r.logger.Debugf("restart docker container: POST %s", endpoint)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("restart container %s: %w", r.container, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusNoContent {
+		body, _ := io.ReadAll(resp.Body)
+		msg := strings.TrimSpace(string(body))
+		var apiErr struct {
+			Message string `json:"message"`
+		}
+		if json.Unmarshal(body, &apiErr) == nil && apiErr.Message != "" {
+			msg = apiErr.Message
+		}
+		return fmt.Errorf("restart container %s error, status code: %v, message: %s", r.container, resp.StatusCode, msg)
+	}
+
+	return nil
+}
+
+func (r *DockerRestarter) Describe(binaryPath string) string {
+	return fmt.Sprintf("%sPOST %s", describeInstall(binaryPath, r.binary.activated()), r.endpoint())
+}
+
+func (r *DockerRestarter) endpoint() string {
+	return fmt.Sprintf("http://docker/containers/%s/restart?t=%d", url.PathEscape(r.container), int(r.stopTimeout.Seconds()))
+}
+
+// NoopRestarter only logs the restart, axiom is left untouched
+type NoopRestarter struct {
+	logger logrus.FieldLogger
+}
+
+func (r *NoopRestarter) Restart(ctx context.Context, binaryPath string) error {
+	r.logger.Infof("noop restart mode, skip restarting axiom with %s", binaryPath)
+	return nil
+}
+
+func (r *NoopRestarter) Describe(binaryPath string) string {
+	return fmt.Sprintf("noop restart mode, axiom is not restarted with %s", binaryPath)
+}
+
+// describeInstall describes installBinary, empty if nothing to install
+func describeInstall(src, dst string) string {
+	if src == dst {
+		return ""
+	}
+	return fmt.Sprintf("install %s as %s, ", src, dst)
+}
+
+// installBinary replaces dst with src, the new binary is copied next to dst first so the replace is a rename.
+// Nothing to do if src is dst, e.g. the live binary of the managed layout.
+func installBinary(src, dst string) error {
+	if src == dst {
+		return nil
+	}
+
+	tmpPath := dst + ".new"
+	if err := copyFile(src, tmpPath); err != nil {
+		return fmt.Errorf("copy new binary: %w", err)
+	}
+
+	if err := os.Rename(tmpPath, dst); err != nil {
+		return fmt.Errorf("replace binary: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,129 @@
+package core
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/axiomesh/guardian/repo"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func newRestarterConfig(t *testing.T, mode string) (*repo.Config, string) {
+	c := repo.DefaultConfig(t.TempDir())
+	c.AxiomPath = t.TempDir()
+	c.Restart.Mode = mode
+	assert.Nil(t, os.WriteFile(filepath.Join(c.AxiomPath, "axiom"), []byte("old"), 0755))
+
+	binaryPath := filepath.Join(t.TempDir(), "axiom")
+	assert.Nil(t, os.WriteFile(binaryPath, []byte("new"), 0755))
+
+	return c, binaryPath
+}
+
+func assertInstalled(t *testing.T, c *repo.Config) {
+	data, err := os.ReadFile(filepath.Join(c.AxiomPath, "axiom"))
+	assert.Nil(t, err)
+	assert.Equal(t, "new", string(data))
+}
+
+func TestScriptRestarter(t *testing.T) {
+	c, binaryPath := newRestarterConfig(t, repo.RestartModeScript)
+	r, err := NewRestarter(c, logrus.New())
+	assert.Nil(t, err)
+
+	// restart.sh not exist
+	assert.NotNil(t, r.Restart(context.Background(), binaryPath))
+
+	script := "cp $1 ./axiom\necho $1 > restarted\n"
+	assert.Nil(t, os.WriteFile(filepath.Join(c.AxiomPath, "restart.sh"), []byte(script), 0755))
+	assert.Nil(t, r.Restart(context.Background(), binaryPath))
+	assertInstalled(t, c)
+
+	data, err := os.ReadFile(filepath.Join(c.AxiomPath, "restarted"))
+	assert.Nil(t, err)
+	assert.Equal(t, binaryPath, strings.TrimSpace(string(data)))
+
+	assert.Nil(t, os.WriteFile(filepath.Join(c.AxiomPath, "restart.sh"), []byte("echo failed\nexit 1\n"), 0755))
+	assert.ErrorContains(t, r.Restart(context.Background(), binaryPath), "failed")
+}
+
+func TestSystemdRestarter(t *testing.T) {
+	c, binaryPath := newRestarterConfig(t, repo.RestartModeSystemd)
+
+	// fake systemctl records its arguments
+	argsFile := filepath.Join(t.TempDir(), "args")
+	c.Restart.Systemd.Systemctl = filepath.Join(t.TempDir(), "systemctl")
+	assert.Nil(t, os.WriteFile(c.Restart.Systemd.Systemctl, []byte("#!/bin/sh\necho $@ > "+argsFile+"\n"), 0755))
+
+	r, err := NewRestarter(c, logrus.New())
+	assert.Nil(t, err)
+	assert.Nil(t, r.Restart(context.Background(), binaryPath))
+	assertInstalled(t, c)
+
+	data, err := os.ReadFile(argsFile)
+	assert.Nil(t, err)
+	assert.Equal(t, "restart axiom.service", strings.TrimSpace(string(data)))
+
+	assert.Nil(t, os.WriteFile(c.Restart.Systemd.Systemctl, []byte("#!/bin/sh\necho unit not found\nexit 5\n"), 0755))
+	assert.ErrorContains(t, r.Restart(context.Background(), binaryPath), "unit not found")
+}
+
+func TestDockerRestarter(t *testing.T) {
+	c, binaryPath := newRestarterConfig(t, repo.RestartModeDocker)
+	c.Restart.Docker.StopTimeout = 10 * time.Second
+
+	// unix socket path length is limited, so not use the nested temp dir
+	socketDir, err := os.MkdirTemp("", "docker")
+	assert.Nil(t, err)
+	defer os.RemoveAll(socketDir)
+	c.Restart.Docker.Socket = filepath.Join(socketDir, "docker.sock")
+
+	listener, err := net.Listen("unix", c.Restart.Docker.Socket)
+	assert.Nil(t, err)
+
+	var requests []string
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.Method+" "+r.URL.RequestURI())
+		if r.URL.Path != "/containers/axiom/restart" {
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"message":"No such container"}`))
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	server.Listener = listener
+	server.Start()
+	defer server.Close()
+
+	r, err := NewRestarter(c, logrus.New())
+	assert.Nil(t, err)
+	assert.Nil(t, r.Restart(context.Background(), binaryPath))
+	assertInstalled(t, c)
+	assert.Equal(t, []string{"POST /containers/axiom/restart?t=10"}, requests)
+
+	r.(*DockerRestarter).container = "missing"
+	assert.ErrorContains(t, r.Restart(context.Background(), binaryPath), "No such container")
+}
+
+func TestNoopRestarter(t *testing.T) {
+	c, binaryPath := newRestarterConfig(t, repo.RestartModeNoop)
+	r, err := NewRestarter(c, logrus.New())
+	assert.Nil(t, err)
+	assert.Nil(t, r.Restart(context.Background(), binaryPath))
+
+	data, err := os.ReadFile(filepath.Join(c.AxiomPath, "axiom"))
+	assert.Nil(t, err)
+	assert.Equal(t, "old", string(data))
+
+	c.Restart.Mode = "unknown"
+	_, err = NewRestarter(c, logrus.New())
+	assert.NotNil(t, err)
+}
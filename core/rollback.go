@@ -0,0 +1,106 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const rollbackKeyPrefix = "rollback-"
+
+// RollbackRecord is stored after the previous release is reinstalled because an upgrade failed
+type RollbackRecord struct {
+	ProposalID  uint64
+	FromVersion string
+	ToVersion   string
+	Reason      string
+	Time        int64
+}
+
+// backupInstalled returns the path of the installed release, rollback reinstalls it.
+// The live release of the managed layout stays on disk, otherwise its files are copied to the backup directory.
+func (g *Guardian) backupInstalled(version *Version) (string, error) {
+	if g.Config.Release.Managed {
+		current, err := g.currentRelease()
+		if err != nil {
+			return "", err
+		}
+		if current != "" {
+			return current, nil
+		}
+	}
+
+	backupPath := filepath.Join(g.Config.RepoRoot, "backup", fmt.Sprintf("axiom-%s", version))
+	if err := os.MkdirAll(backupPath, 0755); err != nil {
+		return "", err
+	}
+
+	for name := range requiredLayout {
+		if err := copyFile(filepath.Join(g.Config.AxiomPath, name), filepath.Join(backupPath, name)); err != nil {
+			return "", fmt.Errorf("backup %s: %w", name, err)
+		}
+	}
+
+	return backupPath, nil
+}
+
+// rollback reinstalls the previous release after the upgrade by proposal failed and records it
+func (g *Guardian) rollback(proposalID uint64, previousPath string, previous *Version, reason error) error {
+	g.Logger.Warnf("upgrade by proposal %d failed, roll back to version %s: %s", proposalID, previous, reason)
+
+	binaryPath, err := g.activate(previousPath, previous)
+	if err != nil {
+		return fmt.Errorf("activate previous release: %w", err)
+	}
+
+	err = g.restarter.Restart(context.Background(), binaryPath)
+	g.metrics.observeRestart(restartReasonRollback, err)
+	if err != nil {
+		return fmt.Errorf("restart previous release: %w", err)
+	}
+	g.metrics.setVersion("current", previous.String())
+
+	record := &RollbackRecord{
+		ProposalID:  proposalID,
+		FromVersion: g.nextUpgradeVersion,
+		ToVersion:   previous.String(),
+		Reason:      reason.Error(),
+		Time:        time.Now().Unix(),
+	}
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	g.DB.Put([]byte(fmt.Sprintf("%s%d", rollbackKeyPrefix, proposalID)), data)
+	g.DB.Put([]byte(nextUpgradeVersion), []byte(previous.String()))
+	g.nextUpgradeVersion = previous.String()
+
+	if err := g.reconnectForHealthCheck(); err != nil {
+		return err
+	}
+
+	if err := g.checkHealth(previous); err != nil {
+		return fmt.Errorf("health check after rollback: %w", err)
+	}
+
+	g.Logger.Infof("roll back to version %s successful", previous)
+	return nil
+}
+
+// getRollbackRecord returns the rollback record of proposal, nil if it is not rolled back
+func (g *Guardian) getRollbackRecord(proposalID uint64) (*RollbackRecord, error) {
+	data := g.DB.Get([]byte(fmt.Sprintf("%s%d", rollbackKeyPrefix, proposalID)))
+	if data == nil {
+		return nil, nil
+	}
+
+	record := &RollbackRecord{}
+	if err := json.Unmarshal(data, record); err != nil {
+		return nil, err
+	}
+
+	return record, nil
+}
@@ -0,0 +1,396 @@
+package core
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/axiomesh/guardian/repo"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	snapshotMetaFileName   = "snapshot.json"
+	snapshotDataDirName    = "data"
+	snapshotArchiveName    = "data.tar.gz"
+	snapshotReleaseDirName = "release"
+)
+
+// SnapshotMeta describes a snapshot of the axiom data directory together with the release that wrote it
+type SnapshotMeta struct {
+	ID         string
+	Version    string
+	ProposalID uint64
+	Mode       string
+	DataPath   string
+	CreatedAt  int64
+}
+
+// SnapshotManager takes snapshots of the axiom data directory before upgrades and restores them
+type SnapshotManager struct {
+	config     *repo.Config
+	mode       string
+	backupPath string
+	logger     logrus.FieldLogger
+}
+
+func NewSnapshotManager(config *repo.Config, logger logrus.FieldLogger) (*SnapshotManager, error) {
+	mode := config.Snapshot.Mode
+	switch mode {
+	case repo.SnapshotModeCopy, repo.SnapshotModeArchive:
+	case repo.SnapshotModeHardlink:
+		logger.Warnf("snapshot mode %s is deprecated, the data directory is copied", mode)
+		mode = repo.SnapshotModeCopy
+	default:
+		return nil, fmt.Errorf("unknown snapshot mode %q", mode)
+	}
+
+	backupPath := config.Snapshot.BackupPath
+	if backupPath == "" {
+		backupPath = filepath.Join(config.RepoRoot, repo.SnapshotsDirName)
+	}
+
+	return &SnapshotManager{
+		config:     config,
+		mode:       mode,
+		backupPath: backupPath,
+		logger:     logger,
+	}, nil
+}
+
+// Create snapshots the data directory and the release in releasePath, then removes snapshots beyond retention
+func (sm *SnapshotManager) Create(version *Version, releasePath string, proposalID uint64) (*SnapshotMeta, error) {
+	now := time.Now()
+	meta := &SnapshotMeta{
+		ID:         fmt.Sprintf("%s-%s", now.Format("20060102150405"), version),
+		Version:    version.String(),
+		ProposalID: proposalID,
+		Mode:       sm.mode,
+		DataPath:   sm.config.Snapshot.DataPath,
+		CreatedAt:  now.Unix(),
+	}
+
+	if err := os.MkdirAll(sm.backupPath, 0755); err != nil {
+		return nil, err
+	}
+
+	// build in a temporary directory, an interrupted snapshot is never listed
+	tmpPath, err := os.MkdirTemp(sm.backupPath, "."+meta.ID+"-")
+	if err != nil {
+		return nil, err
+	}
+	if err := sm.build(tmpPath, releasePath, meta); err != nil {
+		_ = os.RemoveAll(tmpPath)
+		return nil, err
+	}
+	if err := os.Rename(tmpPath, filepath.Join(sm.backupPath, meta.ID)); err != nil {
+		_ = os.RemoveAll(tmpPath)
+		return nil, err
+	}
+	sm.logger.Infof("snapshot %s of %s created", meta.ID, meta.DataPath)
+
+	if err := sm.prune(); err != nil {
+		sm.logger.Errorf("prune snapshots error: %s", err)
+	}
+
+	return meta, nil
+}
+
+func (sm *SnapshotManager) build(dst, releasePath string, meta *SnapshotMeta) error {
+	switch meta.Mode {
+	case repo.SnapshotModeCopy:
+		// never link, axiom writes its files in place after restart
+		if err := copyDir(meta.DataPath, filepath.Join(dst, snapshotDataDirName)); err != nil {
+			return fmt.Errorf("copy data directory: %w", err)
+		}
+	case repo.SnapshotModeArchive:
+		if err := archiveDir(meta.DataPath, filepath.Join(dst, snapshotArchiveName)); err != nil {
+			return fmt.Errorf("archive data directory: %w", err)
+		}
+	}
+
+	releaseDst := filepath.Join(dst, snapshotReleaseDirName)
+	if err := os.Mkdir(releaseDst, 0755); err != nil {
+		return err
+	}
+	for name := range requiredLayout {
+		if err := copyFile(filepath.Join(releasePath, name), filepath.Join(releaseDst, name)); err != nil {
+			return fmt.Errorf("copy release %s: %w", name, err)
+		}
+	}
+
+	data, err := json.MarshalIndent(meta, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dst, snapshotMetaFileName), data, 0644)
+}
+
+// List returns the snapshots ordered from the oldest to the newest
+func (sm *SnapshotManager) List() ([]*SnapshotMeta, error) {
+	entries, err := os.ReadDir(sm.backupPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var metas []*SnapshotMeta
+	for _, entry := range entries {
+		if !entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+
+		meta, err := sm.load(entry.Name())
+		if err != nil {
+			sm.logger.Warnf("skip invalid snapshot %s: %s", entry.Name(), err)
+			continue
+		}
+		metas = append(metas, meta)
+	}
+
+	sort.Slice(metas, func(i, j int) bool {
+		if metas[i].CreatedAt != metas[j].CreatedAt {
+			return metas[i].CreatedAt < metas[j].CreatedAt
+		}
+		return metas[i].ID < metas[j].ID
+	})
+
+	return metas, nil
+}
+
+func (sm *SnapshotManager) load(id string) (*SnapshotMeta, error) {
+	// the id comes from the command line, it must name a snapshot under the backup path
+	if id == "" || id == "." || id == ".." || strings.ContainsAny(id, `/\`) {
+		return nil, fmt.Errorf("invalid snapshot id %q", id)
+	}
+
+	data, err := os.ReadFile(filepath.Join(sm.backupPath, id, snapshotMetaFileName))
+	if err != nil {
+		return nil, err
+	}
+
+	meta := &SnapshotMeta{}
+	if err := json.Unmarshal(data, meta); err != nil {
+		return nil, err
+	}
+
+	return meta, nil
+}
+
+func (sm *SnapshotManager) prune() error {
+	retain := sm.config.Snapshot.Retain
+	if retain <= 0 {
+		return nil
+	}
+
+	metas, err := sm.List()
+	if err != nil {
+		return err
+	}
+
+	for i := 0; i < len(metas)-retain; i++ {
+		if err := os.RemoveAll(filepath.Join(sm.backupPath, metas[i].ID)); err != nil {
+			return err
+		}
+		sm.logger.Infof("snapshot %s removed by retention", metas[i].ID)
+	}
+
+	return nil
+}
+
+// Restore puts back the data directory and the binary of snapshot id, axiom must be stopped.
+// The replaced data directory is kept next to it with a .before-restore suffix.
+func (sm *SnapshotManager) Restore(id string) (*SnapshotMeta, error) {
+	meta, err := sm.load(id)
+	if err != nil {
+		return nil, fmt.Errorf("load snapshot %s: %w", id, err)
+	}
+	version, err := ParseVersion(meta.Version)
+	if err != nil {
+		return nil, err
+	}
+	snapshotPath := filepath.Join(sm.backupPath, id)
+
+	// restore into a temporary directory next to the data directory, then swap them by rename
+	tmpPath := fmt.Sprintf("%s.restore-%d", meta.DataPath, time.Now().UnixNano())
+	switch meta.Mode {
+	case repo.SnapshotModeCopy, repo.SnapshotModeHardlink:
+		// copy instead of link, so the snapshot is not changed by the restored node
+		err = copyDir(filepath.Join(snapshotPath, snapshotDataDirName), tmpPath)
+	case repo.SnapshotModeArchive:
+		err = extractArchive(filepath.Join(snapshotPath, snapshotArchiveName), tmpPath)
+	default:
+		err = fmt.Errorf("unknown snapshot mode %q", meta.Mode)
+	}
+	if err != nil {
+		_ = os.RemoveAll(tmpPath)
+		return nil, fmt.Errorf("restore data directory: %w", err)
+	}
+
+	if _, err := os.Stat(meta.DataPath); err == nil {
+		replacedPath := fmt.Sprintf("%s.before-restore-%d", meta.DataPath, time.Now().Unix())
+		if err := os.Rename(meta.DataPath, replacedPath); err != nil {
+			_ = os.RemoveAll(tmpPath)
+			return nil, err
+		}
+		sm.logger.Infof("replaced data directory is moved to %s", replacedPath)
+	}
+	if err := os.Rename(tmpPath, meta.DataPath); err != nil {
+		return nil, err
+	}
+
+	if err := sm.restoreRelease(filepath.Join(snapshotPath, snapshotReleaseDirName), version); err != nil {
+		return nil, fmt.Errorf("restore release: %w", err)
+	}
+
+	sm.logger.Infof("snapshot %s restored, axiom version %s", id, version)
+	return meta, nil
+}
+
+func (sm *SnapshotManager) restoreRelease(releasePath string, version *Version) error {
+	axiomPath := sm.config.AxiomPath
+	if sm.config.Release.Managed {
+		versionPath, err := installRelease(axiomPath, releasePath, version)
+		if err != nil {
+			return err
+		}
+		return switchCurrent(axiomPath, versionPath)
+	}
+
+	for name := range requiredLayout {
+		if err := installBinary(filepath.Join(releasePath, name), filepath.Join(axiomPath, name)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func archiveDir(src, dst string) error {
+	f, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	gw := gzip.NewWriter(f)
+	tw := tar.NewWriter(gw)
+
+	err = filepath.WalkDir(src, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(src, p)
+		if err != nil || rel == "." {
+			return err
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() && !info.IsDir() {
+			return nil
+		}
+
+		header, err := tar.FileInfoHeader(info, "")
+		if err != nil {
+			return err
+		}
+		header.Name = filepath.ToSlash(rel)
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+
+		file, err := os.Open(p)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+
+		_, err = io.Copy(tw, file)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	if err := gw.Close(); err != nil {
+		return err
+	}
+	return f.Sync()
+}
+
+func extractArchive(src, dst string) error {
+	f, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	gr, err := gzip.NewReader(f)
+	if err != nil {
+		return err
+	}
+	defer gr.Close()
+
+	if err := os.MkdirAll(dst, 0755); err != nil {
+		return err
+	}
+
+	tr := tar.NewReader(gr)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		name := filepath.Clean(filepath.FromSlash(header.Name))
+		if filepath.IsAbs(name) || name == ".." || strings.HasPrefix(name, ".."+string(filepath.Separator)) {
+			return fmt.Errorf("invalid path %q in archive", header.Name)
+		}
+		target := filepath.Join(dst, name)
+
+		switch header.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(target, os.FileMode(header.Mode).Perm()); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+				return err
+			}
+			file, err := os.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, os.FileMode(header.Mode).Perm())
+			if err != nil {
+				return err
+			}
+			if _, err := io.Copy(file, tr); err != nil {
+				file.Close()
+				return err
+			}
+			if err := file.Close(); err != nil {
+				return err
+			}
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package core
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/axiomesh/guardian/repo"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSnapshot(t *testing.T) {
+	for _, mode := range []string{repo.SnapshotModeCopy, repo.SnapshotModeArchive} {
+		t.Run(mode, func(t *testing.T) {
+			c := repo.DefaultConfig(t.TempDir())
+			c.AxiomPath = t.TempDir()
+			c.Snapshot.Enable = true
+			c.Snapshot.Mode = mode
+			c.Snapshot.Retain = 2
+			c.Snapshot.DataPath = filepath.Join(t.TempDir(), "storage")
+			assert.Nil(t, os.MkdirAll(filepath.Join(c.Snapshot.DataPath, "ledger"), 0755))
+			assert.Nil(t, os.WriteFile(filepath.Join(c.Snapshot.DataPath, "ledger", "000001.ldb"), []byte("block 1"), 0644))
+
+			release, _ := writeRelease(t, map[string]string{"axiom": "axiom v1.0.0", "version.sh": "echo 'Axiom version: v1.0.0'"})
+
+			sm, err := NewSnapshotManager(c, logrus.New())
+			assert.Nil(t, err)
+
+			meta, err := sm.Create(&Version{Major: 1}, release, 7)
+			assert.Nil(t, err)
+			assert.Equal(t, "1.0.0", meta.Version)
+			assert.Equal(t, uint64(7), meta.ProposalID)
+
+			// the upgraded node migrates data and binary, files are written in place
+			assert.Nil(t, os.WriteFile(filepath.Join(c.Snapshot.DataPath, "ledger", "000002.ldb"), []byte("migrated"), 0644))
+			f, err := os.OpenFile(filepath.Join(c.Snapshot.DataPath, "ledger", "000001.ldb"), os.O_WRONLY|os.O_APPEND, 0644)
+			assert.Nil(t, err)
+			_, err = f.WriteString(", block 2")
+			assert.Nil(t, err)
+			assert.Nil(t, f.Close())
+			assert.Nil(t, os.WriteFile(filepath.Join(c.AxiomPath, "axiom"), []byte("axiom v1.1.0"), 0755))
+
+			restored, err := sm.Restore(meta.ID)
+			assert.Nil(t, err)
+			assert.Equal(t, meta, restored)
+
+			data, err := os.ReadFile(filepath.Join(c.Snapshot.DataPath, "ledger", "000001.ldb"))
+			assert.Nil(t, err)
+			assert.Equal(t, "block 1", string(data))
+			assert.NoFileExists(t, filepath.Join(c.Snapshot.DataPath, "ledger", "000002.ldb"))
+			data, err = os.ReadFile(filepath.Join(c.AxiomPath, "axiom"))
+			assert.Nil(t, err)
+			assert.Equal(t, "axiom v1.0.0", string(data))
+
+			// replaced data directory is kept
+			matches, err := filepath.Glob(c.Snapshot.DataPath + ".before-restore-*")
+			assert.Nil(t, err)
+			assert.Len(t, matches, 1)
+
+			_, err = sm.Restore("not-exist")
+			assert.NotNil(t, err)
+			for _, id := range []string{"..", "../" + filepath.Base(c.RepoRoot), meta.ID + "/../" + meta.ID} {
+				_, err = sm.Restore(id)
+				assert.ErrorContains(t, err, "invalid snapshot id", id)
+			}
+		})
+	}
+}
+
+func TestSnapshotRetention(t *testing.T) {
+	c := repo.DefaultConfig(t.TempDir())
+	c.Snapshot.Mode = repo.SnapshotModeCopy
+	c.Snapshot.Retain = 2
+	c.Snapshot.DataPath = t.TempDir()
+	assert.Nil(t, os.WriteFile(filepath.Join(c.Snapshot.DataPath, "data"), []byte("data"), 0644))
+	release, _ := writeRelease(t, map[string]string{"axiom": "axiom", "version.sh": "version"})
+
+	sm, err := NewSnapshotManager(c, logrus.New())
+	assert.Nil(t, err)
+
+	var ids []string
+	for i := uint64(0); i < 3; i++ {
+		meta, err := sm.Create(&Version{Major: 1, Minor: i}, release, i)
+		assert.Nil(t, err)
+		ids = append(ids, meta.ID)
+	}
+
+	metas, err := sm.List()
+	assert.Nil(t, err)
+	assert.Len(t, metas, 2)
+	assert.Equal(t, ids[1], metas[0].ID)
+	assert.Equal(t, ids[2], metas[1].ID)
+
+	// the former hard link mode copies
+	c.Snapshot.Mode = repo.SnapshotModeHardlink
+	sm, err = NewSnapshotManager(c, logrus.New())
+	assert.Nil(t, err)
+	meta, err := sm.Create(&Version{Major: 2}, release, 4)
+	assert.Nil(t, err)
+	assert.Equal(t, repo.SnapshotModeCopy, meta.Mode)
+
+	c.Snapshot.Mode = "reflink"
+	_, err = NewSnapshotManager(c, logrus.New())
+	assert.NotNil(t, err)
+}
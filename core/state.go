@@ -0,0 +1,250 @@
+package core
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+const (
+	upgradeKeyPrefix = "upgrade-"
+
+	// currentUpgradeKey holds the proposal id of the upgrade in progress
+	currentUpgradeKey = "currentUpgrade"
+)
+
+type UpgradeState string
+
+const (
+	StateDetected         UpgradeState = "Detected"
+	StateDownloading      UpgradeState = "Downloading"
+	StateVerified         UpgradeState = "Verified"
+	StateAwaitingApproval UpgradeState = "AwaitingApproval"
+	StateStaged           UpgradeState = "Staged"
+	StateRestarting       UpgradeState = "Restarting"
+	StateVerifying        UpgradeState = "Verifying"
+	StateDone             UpgradeState = "Done"
+	StateFailed           UpgradeState = "Failed"
+	StateRolledBack       UpgradeState = "RolledBack"
+)
+
+// Terminal reports whether the upgrade is over in the state
+func (s UpgradeState) Terminal() bool {
+	return s == StateDone || s == StateFailed || s == StateRolledBack
+}
+
+// restarted reports whether axiom may be stopped or running the new release in the state
+func (s UpgradeState) restarted() bool {
+	return s == StateRestarting || s == StateVerifying
+}
+
+type UpgradeTransition struct {
+	State UpgradeState
+	Time  int64
+}
+
+// UpgradeRecord is the persisted progress of the upgrade by a proposal
+type UpgradeRecord struct {
+	ProposalID  uint64
+	State       UpgradeState
+	FromVersion string
+	ToVersion   string
+	// ReleasePath is the verified release to restart with
+	ReleasePath string
+	// PreviousPath is the release to roll back to
+	PreviousPath string
+	Error        string
+	History      []UpgradeTransition
+}
+
+// activeUpgrade is a copy of the upgrade in progress for readers other than the upgrade worker
+type activeUpgrade struct {
+	lock   sync.Mutex
+	record *UpgradeRecord
+	// abort is requested by an operator, the worker checks it between the stages before restart
+	abort bool
+}
+
+func (a *activeUpgrade) set(upgrade *UpgradeRecord) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	if upgrade.State.Terminal() {
+		a.record = nil
+		a.abort = false
+		return
+	}
+	if a.record == nil || a.record.ProposalID != upgrade.ProposalID {
+		a.abort = false
+	}
+	record := *upgrade
+	record.History = append([]UpgradeTransition(nil), upgrade.History...)
+	a.record = &record
+}
+
+// ActiveUpgrade returns the upgrade in progress, nil if none. Unlike CurrentUpgrade it works in dry run mode.
+func (g *Guardian) ActiveUpgrade() *UpgradeRecord {
+	g.active.lock.Lock()
+	defer g.active.lock.Unlock()
+
+	if g.active.record == nil {
+		return nil
+	}
+	record := *g.active.record
+	return &record
+}
+
+// beginUpgrade creates the record of the upgrade by proposal in the Detected state
+func (g *Guardian) beginUpgrade(proposal *NodeProposal, currentVersion *Version) *UpgradeRecord {
+	upgrade := &UpgradeRecord{
+		ProposalID:  proposal.ID,
+		FromVersion: currentVersion.String(),
+		ToVersion:   proposal.Version,
+	}
+	g.transition(upgrade, StateDetected, nil)
+
+	return upgrade
+}
+
+// transition moves the upgrade to state and persists it, reason is recorded for the Failed and RolledBack states.
+// Nothing is persisted in dry run mode.
+func (g *Guardian) transition(upgrade *UpgradeRecord, state UpgradeState, reason error) {
+	now := time.Now().Unix()
+	upgrade.State = state
+	if reason != nil {
+		upgrade.Error = reason.Error()
+	}
+	upgrade.History = append(upgrade.History, UpgradeTransition{State: state, Time: now})
+	g.Logger.Infof("upgrade by proposal %d: %s", upgrade.ProposalID, state)
+	g.active.set(upgrade)
+	if state.Terminal() {
+		g.metrics.setVersion("staged", "")
+		g.auditUpgrade(upgrade)
+	}
+
+	if g.dryRun != nil {
+		return
+	}
+
+	data, err := json.Marshal(upgrade)
+	if err != nil {
+		g.Logger.Errorf("marshal upgrade record error: %s", err)
+		return
+	}
+
+	// the record and the pointer to the upgrade in progress are updated together
+	batch := g.DB.NewBatch()
+	batch.Put([]byte(fmt.Sprintf("%s%d", upgradeKeyPrefix, upgrade.ProposalID)), data)
+	if state.Terminal() {
+		batch.Delete([]byte(currentUpgradeKey))
+	} else {
+		id := make([]byte, 8)
+		binary.BigEndian.PutUint64(id, upgrade.ProposalID)
+		batch.Put([]byte(currentUpgradeKey), id)
+	}
+	batch.Commit()
+}
+
+// failUpgrade moves the upgrade to Failed unless it is already over, e.g. rolled back
+func (g *Guardian) failUpgrade(upgrade *UpgradeRecord, reason error) {
+	if upgrade.State.Terminal() {
+		return
+	}
+	g.transition(upgrade, StateFailed, reason)
+}
+
+// GetUpgradeRecord returns the upgrade record of proposal, nil if it is never upgraded
+func (g *Guardian) GetUpgradeRecord(proposalID uint64) (*UpgradeRecord, error) {
+	data := g.DB.Get([]byte(fmt.Sprintf("%s%d", upgradeKeyPrefix, proposalID)))
+	if data == nil {
+		return nil, nil
+	}
+
+	upgrade := &UpgradeRecord{}
+	if err := json.Unmarshal(data, upgrade); err != nil {
+		return nil, err
+	}
+
+	return upgrade, nil
+}
+
+// CurrentUpgrade returns the upgrade in progress, nil if none
+func (g *Guardian) CurrentUpgrade() (*UpgradeRecord, error) {
+	data := g.DB.Get([]byte(currentUpgradeKey))
+	if len(data) != 8 {
+		return nil, nil
+	}
+
+	return g.GetUpgradeRecord(binary.BigEndian.Uint64(data))
+}
+
+// resumeUpgrade finishes the upgrade interrupted by a guardian crash.
+//
+// An upgrade interrupted before restart has not touched axiom, it is failed and started over by the planner.
+// An upgrade interrupted in restart or verification is verified again, and rolled back if axiom is not healthy.
+// Nothing is resumed in dry run mode.
+func (g *Guardian) resumeUpgrade() error {
+	upgrade, err := g.CurrentUpgrade()
+	if err != nil || upgrade == nil {
+		return err
+	}
+	// the record belongs to a real run, it is left for the next real start to resume
+	if g.dryRun != nil {
+		g.Logger.Warnf("dry run: upgrade by proposal %d interrupted in state %s is left to resume by a real start", upgrade.ProposalID, upgrade.State)
+		return nil
+	}
+	g.Logger.Warnf("resume upgrade by proposal %d interrupted in state %s", upgrade.ProposalID, upgrade.State)
+
+	if !upgrade.State.restarted() {
+		if upgrade.ReleasePath != "" {
+			_ = os.RemoveAll(upgrade.ReleasePath)
+		}
+		g.transition(upgrade, StateFailed, fmt.Errorf("interrupted in state %s before restart, upgrade starts over", upgrade.State))
+		return nil
+	}
+
+	// the restart may have happened, the proposal is consumed either way
+	g.recordUpgradeProposal(upgrade.ProposalID)
+	g.nextUpgradeVersion = upgrade.ToVersion
+	if upgrade.State == StateRestarting {
+		g.transition(upgrade, StateVerifying, nil)
+	} else {
+		g.active.set(upgrade)
+	}
+
+	verifyErr := g.verifyUpgrade()
+	if verifyErr == nil {
+		g.notify(EventRestartSucceeded, upgrade.ProposalID, "axiom runs version %s after guardian restart", upgrade.ToVersion)
+		g.transition(upgrade, StateDone, nil)
+		g.Logger.Infof("upgrade to version %s successful", upgrade.ToVersion)
+		return nil
+	}
+	if g.Ctx.Err() != nil {
+		return verifyErr
+	}
+	g.notify(EventRestartFailed, upgrade.ProposalID, "health check after guardian restart: %s", verifyErr)
+
+	if !g.Config.HealthCheck.Rollback || upgrade.PreviousPath == "" {
+		g.transition(upgrade, StateFailed, verifyErr)
+		return fmt.Errorf("health check of resumed upgrade error: %w", verifyErr)
+	}
+
+	previous, err := ParseVersion(upgrade.FromVersion)
+	if err != nil {
+		g.transition(upgrade, StateFailed, err)
+		return err
+	}
+	if err := g.rollback(upgrade.ProposalID, upgrade.PreviousPath, previous, verifyErr); err != nil {
+		g.notify(EventRestartFailed, upgrade.ProposalID, "roll back to version %s: %s", previous, err)
+		g.transition(upgrade, StateFailed, errors.Join(verifyErr, err))
+		return fmt.Errorf("roll back resumed upgrade error: %w", err)
+	}
+	g.notify(EventRolledBack, upgrade.ProposalID, "rolled back to version %s: %s", previous, verifyErr)
+	g.transition(upgrade, StateRolledBack, verifyErr)
+
+	return nil
+}
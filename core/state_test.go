@@ -0,0 +1,102 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/axiomesh/guardian/repo"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUpgradeTransition(t *testing.T) {
+	c := repo.DefaultConfig(t.TempDir())
+	guardian, err := NewGuardian(context.Background(), c, StaticClientFactory(&MockClient{}))
+	assert.Nil(t, err)
+
+	current, err := guardian.CurrentUpgrade()
+	assert.Nil(t, err)
+	assert.Nil(t, current)
+
+	proposal := &NodeProposal{BaseProposal: BaseProposal{ID: 5}, Version: "1.1.0"}
+	upgrade := guardian.beginUpgrade(proposal, &Version{Major: 1})
+	guardian.transition(upgrade, StateDownloading, nil)
+
+	current, err = guardian.CurrentUpgrade()
+	assert.Nil(t, err)
+	assert.Equal(t, StateDownloading, current.State)
+	assert.Equal(t, "1.0.0", current.FromVersion)
+	assert.Equal(t, "1.1.0", current.ToVersion)
+
+	guardian.transition(upgrade, StateRolledBack, errors.New("block height not advance"))
+	// a finished upgrade is not failed again
+	guardian.failUpgrade(upgrade, errors.New("rolled back"))
+
+	current, err = guardian.CurrentUpgrade()
+	assert.Nil(t, err)
+	assert.Nil(t, current)
+
+	record, err := guardian.GetUpgradeRecord(5)
+	assert.Nil(t, err)
+	assert.Equal(t, StateRolledBack, record.State)
+	assert.Equal(t, "block height not advance", record.Error)
+	assert.Equal(t, 3, len(record.History))
+	assert.Equal(t, StateDetected, record.History[0].State)
+}
+
+func TestResumeUpgradeBeforeRestart(t *testing.T) {
+	c := repo.DefaultConfig(t.TempDir())
+	guardian, err := NewGuardian(context.Background(), c, StaticClientFactory(&MockClient{}))
+	assert.Nil(t, err)
+
+	releasePath, _ := writeRelease(t, map[string]string{"axiom": "axiom v1.1.0", "version.sh": "echo 'Axiom version: v1.1.0'"})
+	proposal := &NodeProposal{BaseProposal: BaseProposal{ID: 5}, Version: "1.1.0"}
+	upgrade := guardian.beginUpgrade(proposal, &Version{Major: 1})
+	upgrade.ReleasePath = releasePath
+	guardian.transition(upgrade, StateVerified, nil)
+
+	assert.Nil(t, guardian.resumeUpgrade())
+
+	record, err := guardian.GetUpgradeRecord(5)
+	assert.Nil(t, err)
+	assert.Equal(t, StateFailed, record.State)
+	assert.Contains(t, record.Error, "before restart")
+	assert.NoDirExists(t, releasePath)
+
+	// the proposal is planned again
+	_, applied := guardian.getLastUpgradeProposal()
+	assert.False(t, applied)
+	current, err := guardian.CurrentUpgrade()
+	assert.Nil(t, err)
+	assert.Nil(t, current)
+}
+
+func TestResumeUpgradeDryRun(t *testing.T) {
+	c := repo.DefaultConfig(t.TempDir())
+	c.AxiomPath = t.TempDir()
+	c.HealthCheck.Rollback = true
+	guardian, err := NewGuardian(context.Background(), c, StaticClientFactory(&MockClient{}))
+	assert.Nil(t, err)
+
+	// interrupted in restart by a real run
+	releasePath, _ := writeRelease(t, map[string]string{"axiom": "axiom v1.1.0", "version.sh": "echo 'Axiom version: v1.1.0'"})
+	previousPath, _ := writeRelease(t, map[string]string{"axiom": "axiom v1.0.0", "version.sh": "echo 'Axiom version: v1.0.0'"})
+	upgrade := guardian.beginUpgrade(&NodeProposal{BaseProposal: BaseProposal{ID: 5}, Version: "1.1.0"}, &Version{Major: 1})
+	upgrade.ReleasePath = releasePath
+	upgrade.PreviousPath = previousPath
+	guardian.transition(upgrade, StateRestarting, nil)
+
+	guardian.dryRun = &dryRunState{}
+	assert.Nil(t, guardian.resumeUpgrade())
+
+	// left as it is for a real start
+	current, err := guardian.CurrentUpgrade()
+	assert.Nil(t, err)
+	assert.Equal(t, StateRestarting, current.State)
+	assert.DirExists(t, releasePath)
+	assert.DirExists(t, previousPath)
+	assert.NoFileExists(t, filepath.Join(c.AxiomPath, "axiom"))
+	_, applied := guardian.getLastUpgradeProposal()
+	assert.False(t, applied)
+}
@@ -0,0 +1,246 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/axiomesh/guardian/repo"
+	"github.com/sirupsen/logrus"
+)
+
+// supervisorStableDuration is how long axiom must run before the crash restart backoff is reset
+const supervisorStableDuration = 10 * time.Minute
+
+var _ Restarter = (*Supervisor)(nil)
+
+// Supervisor runs axiom as a child process, restarts it on crash and swaps its binary on upgrade
+type Supervisor struct {
+	binary      binaryTarget
+	args        []string
+	stopTimeout time.Duration
+	logPath     string
+	minBackoff  time.Duration
+	maxBackoff  time.Duration
+	logger      logrus.FieldLogger
+
+	lock    sync.Mutex
+	cmd     *exec.Cmd
+	exited  chan struct{}
+	backoff time.Duration
+	// running is false when axiom is stopped on purpose, it should not be restarted then
+	running bool
+	// restarts counts restarts after crash
+	restarts uint64
+}
+
+func NewSupervisor(config *repo.Config, logger logrus.FieldLogger) *Supervisor {
+	return &Supervisor{
+		binary:      binaryTarget{config: config},
+		args:        config.Restart.Supervisor.Args,
+		stopTimeout: config.Restart.Supervisor.StopTimeout,
+		logPath:     filepath.Join(config.RepoRoot, repo.LogsDirName, config.Restart.Supervisor.LogFile),
+		minBackoff:  config.Restart.Supervisor.MinRestartBackoff,
+		maxBackoff:  config.Restart.Supervisor.MaxRestartBackoff,
+		logger:      logger,
+		backoff:     config.Restart.Supervisor.MinRestartBackoff,
+	}
+}
+
+// Start starts axiom and keeps it running until Stop is called
+func (s *Supervisor) Start() error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.running {
+		return nil
+	}
+	s.running = true
+
+	if err := s.spawn(); err != nil {
+		s.running = false
+		return err
+	}
+
+	return nil
+}
+
+// spawn starts the axiom process, lock must be held
+func (s *Supervisor) spawn() error {
+	if err := os.MkdirAll(filepath.Dir(s.logPath), 0755); err != nil {
+		return err
+	}
+	logFile, err := os.OpenFile(s.logPath, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("open axiom log file: %w", err)
+	}
+
+	binaryPath := s.binary.path()
+	cmd := exec.Command(binaryPath, s.args...)
+	cmd.Dir = filepath.Dir(binaryPath)
+	cmd.Stdout = logFile
+	cmd.Stderr = logFile
+	if err := cmd.Start(); err != nil {
+		logFile.Close()
+		return fmt.Errorf("start axiom: %w", err)
+	}
+	s.logger.Infof("axiom started, pid: %d", cmd.Process.Pid)
+
+	exited := make(chan struct{})
+	s.cmd = cmd
+	s.exited = exited
+
+	go func() {
+		startTime := time.Now()
+		err := cmd.Wait()
+		logFile.Close()
+		close(exited)
+
+		s.lock.Lock()
+		defer s.lock.Unlock()
+		if !s.running || s.cmd != cmd {
+			return
+		}
+
+		s.logger.Errorf("axiom exited unexpectedly: %v", err)
+		if time.Since(startTime) > supervisorStableDuration {
+			s.backoff = s.minBackoff
+		}
+		s.scheduleRespawn(cmd)
+	}()
+
+	return nil
+}
+
+// scheduleRespawn starts axiom again after the backoff, a failed start is retried with a longer backoff
+// until axiom starts or is stopped on purpose. exited is the process found exited, nil if it was stopped
+// on purpose, lock must be held.
+func (s *Supervisor) scheduleRespawn(exited *exec.Cmd) {
+	delay := s.backoff
+	s.backoff *= 2
+	if s.backoff > s.maxBackoff {
+		s.backoff = s.maxBackoff
+	}
+
+	time.AfterFunc(delay, func() {
+		s.lock.Lock()
+		defer s.lock.Unlock()
+		// stopped, or started again by SwapBinary
+		if !s.running || s.cmd != exited {
+			return
+		}
+
+		s.restarts++
+		s.logger.Infof("restart axiom after %s, restarts: %d", delay, s.restarts)
+		if err := s.spawn(); err != nil {
+			s.logger.Errorf("restart axiom error: %s", err)
+			s.scheduleRespawn(exited)
+		}
+	})
+}
+
+// Stop sends SIGTERM to axiom and kills it if it does not exit within the stop timeout
+func (s *Supervisor) Stop() error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.running = false
+	return s.terminate()
+}
+
+// terminate stops the current axiom process, lock must be held
+func (s *Supervisor) terminate() error {
+	if s.cmd == nil {
+		return nil
+	}
+	cmd, exited := s.cmd, s.exited
+	s.cmd = nil
+
+	select {
+	case <-exited:
+		return nil
+	default:
+	}
+
+	s.logger.Infof("stop axiom, pid: %d", cmd.Process.Pid)
+	if err := cmd.Process.Signal(syscall.SIGTERM); err != nil {
+		s.logger.Warnf("send SIGTERM to axiom error: %s", err)
+	}
+
+	select {
+	case <-exited:
+		return nil
+	case <-time.After(s.stopTimeout):
+	}
+
+	s.logger.Warnf("axiom not exit in %s, kill it", s.stopTimeout)
+	if err := cmd.Process.Kill(); err != nil {
+		return fmt.Errorf("kill axiom: %w", err)
+	}
+	<-exited
+
+	return nil
+}
+
+// SwapBinary stops axiom, replaces its binary with newBinary and starts it again
+func (s *Supervisor) SwapBinary(newBinary string) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if err := s.terminate(); err != nil {
+		return err
+	}
+
+	// the old process is gone already, a failed install or start is retried like after a crash
+	s.running = true
+	s.backoff = s.minBackoff
+	if err := installBinary(newBinary, s.binary.path()); err != nil {
+		s.scheduleRespawn(nil)
+		return err
+	}
+	if err := s.spawn(); err != nil {
+		s.scheduleRespawn(nil)
+		return err
+	}
+
+	return nil
+}
+
+func (s *Supervisor) Restart(ctx context.Context, binaryPath string) error {
+	return s.SwapBinary(binaryPath)
+}
+
+func (s *Supervisor) Describe(binaryPath string) string {
+	livePath := s.binary.activated()
+	return fmt.Sprintf("stop axiom, %sstart: %s %s", describeInstall(binaryPath, livePath), livePath, strings.Join(s.args, " "))
+}
+
+// Running reports whether axiom process is alive
+func (s *Supervisor) Running() bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.cmd == nil {
+		return false
+	}
+	select {
+	case <-s.exited:
+		return false
+	default:
+		return true
+	}
+}
+
+// Restarts returns how many times axiom is restarted after crash
+func (s *Supervisor) Restarts() uint64 {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return s.restarts
+}
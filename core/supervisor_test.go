@@ -0,0 +1,151 @@
+package core
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/axiomesh/guardian/repo"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestSupervisor(t *testing.T, script string) (*Supervisor, *repo.Config) {
+	c := repo.DefaultConfig(t.TempDir())
+	c.AxiomPath = t.TempDir()
+	c.Restart.Mode = repo.RestartModeSupervisor
+	c.Restart.Supervisor.StopTimeout = time.Second
+	c.Restart.Supervisor.MinRestartBackoff = 10 * time.Millisecond
+	c.Restart.Supervisor.MaxRestartBackoff = 50 * time.Millisecond
+	assert.Nil(t, os.WriteFile(filepath.Join(c.AxiomPath, "axiom"), []byte(script), 0755))
+
+	return NewSupervisor(c, logrus.New()), c
+}
+
+func readAxiomLog(t *testing.T, c *repo.Config) string {
+	data, err := os.ReadFile(filepath.Join(c.RepoRoot, repo.LogsDirName, c.Restart.Supervisor.LogFile))
+	assert.Nil(t, err)
+	return string(data)
+}
+
+func TestSupervisorStartStop(t *testing.T) {
+	s, c := newTestSupervisor(t, "#!/bin/sh\necho axiom $@\ntrap 'echo graceful; exit 0' TERM\nwhile true; do sleep 0.05; done\n")
+
+	assert.Nil(t, s.Start())
+	assert.True(t, s.Running())
+	assert.Eventually(t, func() bool {
+		return strings.Contains(readAxiomLog(t, c), "axiom start")
+	}, 5*time.Second, 10*time.Millisecond)
+
+	assert.Nil(t, s.Stop())
+	assert.False(t, s.Running())
+	assert.Contains(t, readAxiomLog(t, c), "graceful")
+}
+
+func TestSupervisorKillAfterTimeout(t *testing.T) {
+	s, _ := newTestSupervisor(t, "#!/bin/sh\ntrap '' TERM\nwhile true; do sleep 0.05; done\n")
+	s.stopTimeout = 100 * time.Millisecond
+
+	assert.Nil(t, s.Start())
+	time.Sleep(100 * time.Millisecond)
+
+	start := time.Now()
+	assert.Nil(t, s.Stop())
+	assert.False(t, s.Running())
+	assert.GreaterOrEqual(t, time.Since(start), s.stopTimeout)
+}
+
+func TestSupervisorRestartOnCrash(t *testing.T) {
+	s, c := newTestSupervisor(t, "#!/bin/sh\necho crash\nexit 1\n")
+
+	assert.Nil(t, s.Start())
+	assert.Eventually(t, func() bool {
+		return s.Restarts() >= 3
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.Nil(t, s.Stop())
+	assert.GreaterOrEqual(t, strings.Count(readAxiomLog(t, c), "crash"), 3)
+}
+
+func TestSupervisorSwapBinary(t *testing.T) {
+	s, c := newTestSupervisor(t, "#!/bin/sh\necho old\nwhile true; do sleep 0.05; done\n")
+	assert.Nil(t, s.Start())
+
+	newBinary := filepath.Join(t.TempDir(), "axiom")
+	assert.Nil(t, os.WriteFile(newBinary, []byte("#!/bin/sh\necho new\nwhile true; do sleep 0.05; done\n"), 0755))
+	assert.Nil(t, s.SwapBinary(newBinary))
+	assert.True(t, s.Running())
+
+	assert.Eventually(t, func() bool {
+		return strings.Contains(readAxiomLog(t, c), "new")
+	}, 5*time.Second, 10*time.Millisecond)
+	data, err := os.ReadFile(filepath.Join(c.AxiomPath, "axiom"))
+	assert.Nil(t, err)
+	assert.Contains(t, string(data), "echo new")
+
+	assert.Nil(t, s.Stop())
+}
+
+func TestSupervisorSwapBinaryStartFailed(t *testing.T) {
+	s, c := newTestSupervisor(t, "#!/bin/sh\necho old\nwhile true; do sleep 0.05; done\n")
+	assert.Nil(t, s.Start())
+
+	// not executable, the start after the swap fails
+	newBinary := filepath.Join(t.TempDir(), "axiom")
+	assert.Nil(t, os.WriteFile(newBinary, []byte("#!/bin/sh\necho new\nwhile true; do sleep 0.05; done\n"), 0644))
+	assert.NotNil(t, s.SwapBinary(newBinary))
+	assert.False(t, s.Running())
+
+	// retried until it starts
+	assert.Nil(t, os.Chmod(filepath.Join(c.AxiomPath, "axiom"), 0755))
+	assert.Eventually(t, func() bool {
+		return s.Running() && strings.Contains(readAxiomLog(t, c), "new")
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.Nil(t, s.Stop())
+}
+
+func TestSupervisorRespawnRetry(t *testing.T) {
+	s, c := newTestSupervisor(t, "#!/bin/sh\necho crash\nexit 1\n")
+	binaryPath := filepath.Join(c.AxiomPath, "axiom")
+
+	assert.Nil(t, s.Start())
+	// the binary is gone while axiom is down, the respawn keeps failing
+	assert.Nil(t, os.Remove(binaryPath))
+	before := s.Restarts()
+	assert.Eventually(t, func() bool {
+		return s.Restarts() >= before+3
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.False(t, s.Running())
+
+	// restarted once the binary is back
+	assert.Nil(t, os.WriteFile(binaryPath, []byte("#!/bin/sh\necho back\nwhile true; do sleep 0.05; done\n"), 0755))
+	assert.Eventually(t, func() bool {
+		return s.Running() && strings.Contains(readAxiomLog(t, c), "back")
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.Nil(t, s.Stop())
+}
+
+func TestSupervisorManagedLayout(t *testing.T) {
+	s, c := newTestSupervisor(t, "#!/bin/sh\necho legacy\nwhile true; do sleep 0.05; done\n")
+	c.Release.Managed = true
+
+	// not switched yet, the legacy binary is run
+	assert.Nil(t, s.Start())
+	assert.Eventually(t, func() bool {
+		return strings.Contains(readAxiomLog(t, c), "legacy")
+	}, 5*time.Second, 10*time.Millisecond)
+
+	releasePath := filepath.Join(c.AxiomPath, ReleasesDirName, "1.1.0")
+	assert.Nil(t, os.MkdirAll(releasePath, 0755))
+	assert.Nil(t, os.WriteFile(filepath.Join(releasePath, "axiom"), []byte("#!/bin/sh\necho managed\nwhile true; do sleep 0.05; done\n"), 0755))
+	assert.Nil(t, switchCurrent(c.AxiomPath, releasePath))
+	livePath := filepath.Join(c.AxiomPath, CurrentLinkName, "axiom")
+	assert.Contains(t, s.Describe(livePath), "start: "+livePath)
+
+	assert.Nil(t, s.SwapBinary(livePath))
+	assert.Eventually(t, func() bool {
+		return strings.Contains(readAxiomLog(t, c), "managed")
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.Nil(t, s.Stop())
+}
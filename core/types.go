@@ -24,6 +24,21 @@ const (
 	NodeRemove
 )
 
+func (t ProposalType) String() string {
+	switch t {
+	case CouncilElect:
+		return "council_elect"
+	case NodeUpgrade:
+		return "node_upgrade"
+	case NodeAdd:
+		return "node_add"
+	case NodeRemove:
+		return "node_remove"
+	default:
+		return "unknown"
+	}
+}
+
 type ProposalStrategy uint8
 
 const (
@@ -55,4 +70,21 @@ type NodeProposal struct {
 	BaseProposal
 	DownloadUrls []string
 	CheckHash    string
+
+	// Version is the axiom version shipped in the release, optional,
+	// it is used to plan the upgrade path and must match the version of the downloaded release
+	Version string
+
+	// SourceVersionConstraint is the range of installed versions the proposal applies to, e.g. ">=1.2.0 <1.4.0",
+	// empty means any version
+	SourceVersionConstraint string
+
+	// Rollback allows the release to be older than or equal to the installed version
+	Rollback bool
+
+	// Delta is an optional binary patch of the axiom binary, the full release is used if it can not be applied
+	Delta *DeltaArtifact
+
+	// TxHash is the transaction emitting the proposal log, it is not part of the proposal data
+	TxHash string `json:"-"`
 }
@@ -0,0 +1,249 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	ErrInvalidVersion = errors.New("invalid semantic version")
+
+	// versionRegexp matches a semantic version 2.0.0 with an optional v prefix
+	versionRegexp = regexp.MustCompile(`v?(0|[1-9]\d*)\.(0|[1-9]\d*)\.(0|[1-9]\d*)` +
+		`(?:-((?:0|[1-9]\d*|\d*[a-zA-Z-][0-9a-zA-Z-]*)(?:\.(?:0|[1-9]\d*|\d*[a-zA-Z-][0-9a-zA-Z-]*))*))?` +
+		`(?:\+([0-9a-zA-Z-]+(?:\.[0-9a-zA-Z-]+)*))?`)
+)
+
+// Version is a semantic version, see https://semver.org
+type Version struct {
+	Major uint64
+	Minor uint64
+	Patch uint64
+
+	PreRelease []string
+	Build      []string
+}
+
+// ParseVersion parses s as a semantic version, a leading v is allowed
+func ParseVersion(s string) (*Version, error) {
+	s = strings.TrimSpace(s)
+	loc := versionRegexp.FindStringSubmatchIndex(s)
+	if loc == nil || loc[0] != 0 || loc[1] != len(s) {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidVersion, s)
+	}
+
+	return newVersion(versionRegexp.FindStringSubmatch(s))
+}
+
+// ExtractVersion returns the first semantic version contained in s
+func ExtractVersion(s string) (*Version, error) {
+	match := versionRegexp.FindStringSubmatch(s)
+	if match == nil {
+		return nil, fmt.Errorf("%w: no version found in %q", ErrInvalidVersion, s)
+	}
+
+	return newVersion(match)
+}
+
+func newVersion(match []string) (*Version, error) {
+	v := &Version{}
+	var err error
+	if v.Major, err = strconv.ParseUint(match[1], 10, 64); err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidVersion, err)
+	}
+	if v.Minor, err = strconv.ParseUint(match[2], 10, 64); err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidVersion, err)
+	}
+	if v.Patch, err = strconv.ParseUint(match[3], 10, 64); err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidVersion, err)
+	}
+	if match[4] != "" {
+		v.PreRelease = strings.Split(match[4], ".")
+	}
+	if match[5] != "" {
+		v.Build = strings.Split(match[5], ".")
+	}
+
+	return v, nil
+}
+
+func (v *Version) String() string {
+	s := fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch)
+	if len(v.PreRelease) != 0 {
+		s += "-" + strings.Join(v.PreRelease, ".")
+	}
+	if len(v.Build) != 0 {
+		s += "+" + strings.Join(v.Build, ".")
+	}
+	return s
+}
+
+// Compare returns -1, 0 or 1 if v has lower, equal or higher precedence than o,
+// build metadata is ignored
+func (v *Version) Compare(o *Version) int {
+	if c := compareUint(v.Major, o.Major); c != 0 {
+		return c
+	}
+	if c := compareUint(v.Minor, o.Minor); c != 0 {
+		return c
+	}
+	if c := compareUint(v.Patch, o.Patch); c != 0 {
+		return c
+	}
+
+	// a version without pre-release has higher precedence
+	switch {
+	case len(v.PreRelease) == 0 && len(o.PreRelease) == 0:
+		return 0
+	case len(v.PreRelease) == 0:
+		return 1
+	case len(o.PreRelease) == 0:
+		return -1
+	}
+
+	for i := 0; i < len(v.PreRelease) && i < len(o.PreRelease); i++ {
+		if c := comparePreRelease(v.PreRelease[i], o.PreRelease[i]); c != 0 {
+			return c
+		}
+	}
+
+	return compareUint(uint64(len(v.PreRelease)), uint64(len(o.PreRelease)))
+}
+
+func comparePreRelease(a, b string) int {
+	an, aErr := strconv.ParseUint(a, 10, 64)
+	bn, bErr := strconv.ParseUint(b, 10, 64)
+	switch {
+	case aErr == nil && bErr == nil:
+		return compareUint(an, bn)
+	case aErr == nil:
+		// numeric identifiers have lower precedence than alphanumeric ones
+		return -1
+	case bErr == nil:
+		return 1
+	default:
+		return strings.Compare(a, b)
+	}
+}
+
+func compareUint(a, b uint64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// parseVersionOutput finds the version in the output of version.sh, e.g. "Axiom version: v1.2.0-rc.1"
+func parseVersionOutput(out string) (*Version, error) {
+	for _, line := range strings.Split(out, "\n") {
+		key, value, found := strings.Cut(line, ":")
+		if !found || !strings.Contains(strings.ToLower(key), "version") {
+			continue
+		}
+
+		// only the first version line belongs to axiom, later ones may be e.g. the golang version
+		return ExtractVersion(value)
+	}
+
+	return nil, fmt.Errorf("%w: version output %q not contains a version line", ErrInvalidVersion, out)
+}
+
+var ErrInvalidConstraint = errors.New("invalid version constraint")
+
+type comparator struct {
+	op      string
+	version *Version
+}
+
+func (c comparator) check(v *Version) bool {
+	r := v.Compare(c.version)
+	switch c.op {
+	case ">=":
+		return r >= 0
+	case "<=":
+		return r <= 0
+	case ">":
+		return r > 0
+	case "<":
+		return r < 0
+	case "!=":
+		return r != 0
+	default:
+		return r == 0
+	}
+}
+
+// Constraint is a version range, e.g. ">=1.2.0 <1.4.0 || 2.0.0",
+// comparators separated by spaces or commas must all match, groups separated by || match if any of them matches
+type Constraint struct {
+	raw    string
+	groups [][]comparator
+}
+
+func ParseConstraint(s string) (*Constraint, error) {
+	c := &Constraint{raw: strings.TrimSpace(s)}
+	for _, group := range strings.Split(s, "||") {
+		fields := strings.FieldsFunc(group, func(r rune) bool {
+			return r == ' ' || r == ',' || r == '\t'
+		})
+
+		var comparators []comparator
+		for i := 0; i < len(fields); i++ {
+			field := fields[i]
+			op := strings.TrimRight(field, "0123456789.-+abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZv")
+			// allow a space between operator and version, e.g. ">= 1.2.0"
+			if op == field && i+1 < len(fields) {
+				i++
+				field += fields[i]
+			}
+
+			switch op {
+			case ">=", "<=", ">", "<", "=", "!=", "":
+			default:
+				return nil, fmt.Errorf("%w: unknown operator %q in %q", ErrInvalidConstraint, op, s)
+			}
+
+			v, err := ParseVersion(strings.TrimPrefix(field, op))
+			if err != nil {
+				return nil, fmt.Errorf("%w: %q: %s", ErrInvalidConstraint, s, err)
+			}
+			comparators = append(comparators, comparator{op: op, version: v})
+		}
+
+		if len(comparators) == 0 {
+			return nil, fmt.Errorf("%w: empty range in %q", ErrInvalidConstraint, s)
+		}
+		c.groups = append(c.groups, comparators)
+	}
+
+	return c, nil
+}
+
+// Check reports whether v satisfies the constraint
+func (c *Constraint) Check(v *Version) bool {
+	for _, group := range c.groups {
+		matched := true
+		for _, comparator := range group {
+			if !comparator.check(v) {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (c *Constraint) String() string {
+	return c.raw
+}
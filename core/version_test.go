@@ -0,0 +1,94 @@
+package core
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseVersion(t *testing.T) {
+	v, err := ParseVersion("v1.2.3-rc.1+build.5")
+	assert.Nil(t, err)
+	assert.Equal(t, &Version{Major: 1, Minor: 2, Patch: 3, PreRelease: []string{"rc", "1"}, Build: []string{"build", "5"}}, v)
+	assert.Equal(t, "1.2.3-rc.1+build.5", v.String())
+
+	for _, s := range []string{"", "1.2", "01.2.3", "1.2.3-", "1.2.3 extra", "dev"} {
+		_, err := ParseVersion(s)
+		assert.ErrorIs(t, err, ErrInvalidVersion, s)
+	}
+}
+
+func TestVersionCompare(t *testing.T) {
+	// ordered by precedence, see https://semver.org/#spec-item-11
+	ordered := []string{
+		"1.0.0-alpha",
+		"1.0.0-alpha.1",
+		"1.0.0-alpha.beta",
+		"1.0.0-beta",
+		"1.0.0-beta.2",
+		"1.0.0-beta.11",
+		"1.0.0-rc.1",
+		"1.0.0",
+		"1.0.1",
+		"1.1.0",
+		"2.0.0",
+	}
+	for i := range ordered {
+		for j := range ordered {
+			a, err := ParseVersion(ordered[i])
+			assert.Nil(t, err)
+			b, err := ParseVersion(ordered[j])
+			assert.Nil(t, err)
+
+			expected := compareUint(uint64(i), uint64(j))
+			assert.Equal(t, expected, a.Compare(b), "%s vs %s", ordered[i], ordered[j])
+		}
+	}
+
+	a, _ := ParseVersion("1.0.0+build.1")
+	b, _ := ParseVersion("1.0.0+build.2")
+	assert.Equal(t, 0, a.Compare(b))
+}
+
+func TestParseVersionOutput(t *testing.T) {
+	v, err := parseVersionOutput("Axiom version: v0.1.0-dev-main-a1b2c3\nApp build date: 2023-08-01T10:00:00\nSystem version: linux/amd64\n")
+	assert.Nil(t, err)
+	assert.Equal(t, "0.1.0-dev-main-a1b2c3", v.String())
+
+	_, err = parseVersionOutput("Axiom version: dev-main-a1b2c3\nGolang version: go1.20.5\n")
+	assert.ErrorIs(t, err, ErrInvalidVersion)
+}
+
+func TestConstraint(t *testing.T) {
+	tests := []struct {
+		constraint string
+		matched    []string
+		unmatched  []string
+	}{
+		{">=1.2.0 <1.4.0", []string{"1.2.0", "1.3.9", "1.4.0-rc.1"}, []string{"1.1.9", "1.2.0-rc.1", "1.4.0"}},
+		{">= 1.2.0, < 1.4.0", []string{"1.2.0", "1.3.0"}, []string{"1.4.0"}},
+		{"1.0.0 || >2.0.0", []string{"1.0.0", "2.0.1"}, []string{"1.0.1", "2.0.0"}},
+		{"!=1.1.0", []string{"1.0.0", "1.2.0"}, []string{"1.1.0"}},
+		{"=v1.1.0", []string{"1.1.0"}, []string{"1.1.1"}},
+	}
+
+	for _, test := range tests {
+		c, err := ParseConstraint(test.constraint)
+		assert.Nil(t, err, test.constraint)
+		for _, s := range test.matched {
+			v, err := ParseVersion(s)
+			assert.Nil(t, err)
+			assert.True(t, c.Check(v), "%s should match %s", s, test.constraint)
+		}
+		for _, s := range test.unmatched {
+			v, err := ParseVersion(s)
+			assert.Nil(t, err)
+			assert.False(t, c.Check(v), "%s should not match %s", s, test.constraint)
+		}
+	}
+
+	for _, s := range []string{"", ">=1.2.0 ||", "~1.2.0", ">=1.2", "=>1.2.0"} {
+		_, err := ParseConstraint(s)
+		assert.ErrorIs(t, err, ErrInvalidConstraint, s)
+	}
+}
@@ -0,0 +1,273 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/axiomesh/guardian/repo"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	defaultWebhookTimeout = 10 * time.Second
+	webhookRetryBackoff   = time.Second
+
+	// WebhookSignatureHeader carries "sha256=" and the hex hmac-sha256 of "<timestamp>.<body>" keyed by the webhook secret
+	WebhookSignatureHeader = "X-Guardian-Signature"
+	// WebhookTimestampHeader is the unix time the request is signed, receivers should refuse stale ones
+	WebhookTimestampHeader = "X-Guardian-Timestamp"
+)
+
+type EventType string
+
+const (
+	EventProposalDetected EventType = "proposal_detected"
+	EventDownloadFailed   EventType = "download_failed"
+	EventHashMismatch     EventType = "hash_mismatch"
+	EventRestartStarted   EventType = "restart_started"
+	EventRestartSucceeded EventType = "restart_succeeded"
+	EventRestartFailed    EventType = "restart_failed"
+	EventRolledBack       EventType = "rolled_back"
+)
+
+var eventTypes = map[EventType]bool{
+	EventProposalDetected: true,
+	EventDownloadFailed:   true,
+	EventHashMismatch:     true,
+	EventRestartStarted:   true,
+	EventRestartSucceeded: true,
+	EventRestartFailed:    true,
+	EventRolledBack:       true,
+}
+
+// WebhookEvent is posted to the generic webhooks in json
+type WebhookEvent struct {
+	Event       EventType `json:"event"`
+	ProposalID  uint64    `json:"proposal_id"`
+	FromVersion string    `json:"from_version,omitempty"`
+	ToVersion   string    `json:"to_version,omitempty"`
+	Message     string    `json:"message"`
+	Time        int64     `json:"time"`
+}
+
+// slackText is the message posted to the slack webhooks
+func (e *WebhookEvent) slackText() string {
+	text := fmt.Sprintf("guardian: %s of proposal %d", e.Event, e.ProposalID)
+	if e.ToVersion != "" {
+		text += fmt.Sprintf(" (%s -> %s)", e.FromVersion, e.ToVersion)
+	}
+	if e.Message != "" {
+		text += ": " + e.Message
+	}
+	return text
+}
+
+// Notifier posts upgrade lifecycle events to the configured webhooks.
+// Every event is delivered in the background, a slow receiver never holds up the upgrade.
+// The events of a webhook are delivered one by one in the order notified.
+type Notifier struct {
+	ctx     context.Context
+	senders []*webhookSender
+	client  *http.Client
+	logger  logrus.FieldLogger
+	// wg tracks the events not delivered or given up yet
+	wg sync.WaitGroup
+}
+
+// webhookSender queues the events of a webhook, a single goroutine delivers them while any is queued
+type webhookSender struct {
+	webhook repo.Webhook
+	lock    sync.Mutex
+	pending []*WebhookEvent
+	running bool
+}
+
+// NewNotifier checks the webhooks, deliveries are canceled once ctx is done
+func NewNotifier(ctx context.Context, webhooks []repo.Webhook, logger logrus.FieldLogger) (*Notifier, error) {
+	for _, webhook := range webhooks {
+		if webhook.URL == "" {
+			return nil, fmt.Errorf("webhook %s: url is empty", webhook.Name)
+		}
+		switch webhook.Format {
+		case "", repo.WebhookFormatGeneric, repo.WebhookFormatSlack:
+		default:
+			return nil, fmt.Errorf("webhook %s: unsupported format %s", webhook.Name, webhook.Format)
+		}
+		for _, event := range webhook.Events {
+			if !eventTypes[EventType(event)] {
+				return nil, fmt.Errorf("webhook %s: unknown event %s", webhook.Name, event)
+			}
+		}
+	}
+
+	senders := make([]*webhookSender, 0, len(webhooks))
+	for _, webhook := range webhooks {
+		senders = append(senders, &webhookSender{webhook: webhook})
+	}
+
+	return &Notifier{
+		ctx:     ctx,
+		senders: senders,
+		client:  &http.Client{},
+		logger:  logger,
+	}, nil
+}
+
+// Notify posts event to every webhook subscribed to it
+func (n *Notifier) Notify(event *WebhookEvent) {
+	if event.Time == 0 {
+		event.Time = time.Now().Unix()
+	}
+
+	for _, sender := range n.senders {
+		if !subscribed(sender.webhook, event.Event) {
+			continue
+		}
+
+		n.wg.Add(1)
+		sender.lock.Lock()
+		sender.pending = append(sender.pending, event)
+		if !sender.running {
+			sender.running = true
+			go n.send(sender)
+		}
+		sender.lock.Unlock()
+	}
+}
+
+// send delivers the queued events of the webhook in order until none is left
+func (n *Notifier) send(sender *webhookSender) {
+	for {
+		sender.lock.Lock()
+		if len(sender.pending) == 0 {
+			sender.running = false
+			sender.lock.Unlock()
+			return
+		}
+		event := sender.pending[0]
+		sender.pending = sender.pending[1:]
+		sender.lock.Unlock()
+
+		if err := n.deliver(sender.webhook, event); err != nil {
+			n.logger.Errorf("post %s event to webhook %s error: %s", event.Event, sender.webhook.Name, err)
+		}
+		n.wg.Done()
+	}
+}
+
+// Wait blocks until the events notified are delivered or given up
+func (n *Notifier) Wait() {
+	n.wg.Wait()
+}
+
+func subscribed(webhook repo.Webhook, event EventType) bool {
+	if len(webhook.Events) == 0 {
+		return true
+	}
+	for _, e := range webhook.Events {
+		if EventType(e) == event {
+			return true
+		}
+	}
+	return false
+}
+
+// deliver posts event and retries on network errors and server errors, the delay doubles on every retry
+func (n *Notifier) deliver(webhook repo.Webhook, event *WebhookEvent) error {
+	var payload any = event
+	if webhook.Format == repo.WebhookFormatSlack {
+		payload = map[string]string{"text": event.slackText()}
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	delay := webhookRetryBackoff
+	for attempt := 0; ; attempt++ {
+		retryable, err := n.post(webhook, body)
+		if err == nil {
+			return nil
+		}
+		if !retryable || attempt >= webhook.Retries {
+			return err
+		}
+		n.logger.Warnf("post %s event to webhook %s error: %s, retry in %s", event.Event, webhook.Name, err, delay)
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-n.ctx.Done():
+			timer.Stop()
+			return n.ctx.Err()
+		}
+		delay *= 2
+	}
+}
+
+// post sends body once, it reports whether a failure is worth retrying
+func (n *Notifier) post(webhook repo.Webhook, body []byte) (bool, error) {
+	timeout := webhook.Timeout
+	if timeout <= 0 {
+		timeout = defaultWebhookTimeout
+	}
+	ctx, cancel := context.WithTimeout(n.ctx, timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhook.URL, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if webhook.Secret != "" {
+		timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+		req.Header.Set(WebhookTimestampHeader, timestamp)
+		req.Header.Set(WebhookSignatureHeader, "sha256="+SignWebhook(webhook.Secret, timestamp, body))
+	}
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return n.ctx.Err() == nil, err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode >= http.StatusMultipleChoices {
+		retryable := resp.StatusCode >= http.StatusInternalServerError || resp.StatusCode == http.StatusTooManyRequests
+		return retryable, fmt.Errorf("status code: %v", resp.StatusCode)
+	}
+	return false, nil
+}
+
+// SignWebhook returns the hex hmac-sha256 of "<timestamp>.<body>" keyed by secret, receivers compute it to check a request
+func SignWebhook(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte{'.'})
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// notify posts the event of the upgrade by proposal, the versions are taken from the upgrade in progress
+func (g *Guardian) notify(event EventType, proposalID uint64, format string, args ...any) {
+	e := &WebhookEvent{
+		Event:      event,
+		ProposalID: proposalID,
+		Message:    fmt.Sprintf(format, args...),
+	}
+	if upgrade := g.ActiveUpgrade(); upgrade != nil && upgrade.ProposalID == proposalID {
+		e.FromVersion = upgrade.FromVersion
+		e.ToVersion = upgrade.ToVersion
+	}
+	g.notifier.Notify(e)
+}
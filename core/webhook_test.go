@@ -0,0 +1,205 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/axiomesh/guardian/repo"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+type webhookRequest struct {
+	path      string
+	body      []byte
+	signature string
+	timestamp string
+}
+
+// newWebhookReceiver records the requests, the paths in failures fail the first request with the status
+func newWebhookReceiver(t *testing.T, failures map[string]int) (*httptest.Server, func() []webhookRequest) {
+	var lock sync.Mutex
+	var requests []webhookRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		assert.Nil(t, err)
+
+		lock.Lock()
+		defer lock.Unlock()
+		requests = append(requests, webhookRequest{
+			path:      r.URL.Path,
+			body:      body,
+			signature: r.Header.Get(WebhookSignatureHeader),
+			timestamp: r.Header.Get(WebhookTimestampHeader),
+		})
+		if status, ok := failures[r.URL.Path]; ok {
+			delete(failures, r.URL.Path)
+			w.WriteHeader(status)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	return server, func() []webhookRequest {
+		lock.Lock()
+		defer lock.Unlock()
+		return append([]webhookRequest(nil), requests...)
+	}
+}
+
+func TestNotifier(t *testing.T) {
+	server, requests := newWebhookReceiver(t, map[string]int{"/generic": http.StatusInternalServerError, "/rejected": http.StatusBadRequest})
+
+	notifier, err := NewNotifier(context.Background(), []repo.Webhook{
+		{Name: "generic", URL: server.URL + "/generic", Secret: "secret", Retries: 2},
+		{Name: "slack", URL: server.URL + "/slack", Format: repo.WebhookFormatSlack, Events: []string{string(EventRestartSucceeded)}},
+		{Name: "filtered", URL: server.URL + "/filtered", Events: []string{string(EventHashMismatch)}},
+		{Name: "rejected", URL: server.URL + "/rejected", Retries: 2},
+	}, logrus.New())
+	assert.Nil(t, err)
+
+	notifier.Notify(&WebhookEvent{Event: EventRestartSucceeded, ProposalID: 3, FromVersion: "1.0.0", ToVersion: "1.1.0", Message: "axiom runs version 1.1.0"})
+	notifier.Wait()
+
+	byPath := make(map[string][]webhookRequest)
+	for _, r := range requests() {
+		byPath[r.path] = append(byPath[r.path], r)
+	}
+
+	// retried after the server error
+	assert.Len(t, byPath["/generic"], 2)
+	generic := byPath["/generic"][1]
+	event := &WebhookEvent{}
+	assert.Nil(t, json.Unmarshal(generic.body, event))
+	assert.Equal(t, EventRestartSucceeded, event.Event)
+	assert.Equal(t, uint64(3), event.ProposalID)
+	assert.Equal(t, "1.1.0", event.ToVersion)
+	assert.NotZero(t, event.Time)
+	assert.Equal(t, "sha256="+SignWebhook("secret", generic.timestamp, generic.body), generic.signature)
+
+	assert.Len(t, byPath["/slack"], 1)
+	assert.JSONEq(t, `{"text":"guardian: restart_succeeded of proposal 3 (1.0.0 -> 1.1.0): axiom runs version 1.1.0"}`, string(byPath["/slack"][0].body))
+	assert.Empty(t, byPath["/slack"][0].signature)
+
+	assert.Empty(t, byPath["/filtered"])
+	// client errors are not retried
+	assert.Len(t, byPath["/rejected"], 1)
+
+	_, err = NewNotifier(context.Background(), []repo.Webhook{{Name: "bad", URL: server.URL, Events: []string{"upgrade_started"}}}, logrus.New())
+	assert.ErrorContains(t, err, "unknown event upgrade_started")
+	_, err = NewNotifier(context.Background(), []repo.Webhook{{Name: "bad", URL: server.URL, Format: "teams"}}, logrus.New())
+	assert.ErrorContains(t, err, "unsupported format teams")
+}
+
+func TestNotifierOrder(t *testing.T) {
+	server, requests := newWebhookReceiver(t, map[string]int{"/generic": http.StatusServiceUnavailable})
+
+	notifier, err := NewNotifier(context.Background(), []repo.Webhook{{Name: "generic", URL: server.URL + "/generic", Retries: 1}}, logrus.New())
+	assert.Nil(t, err)
+
+	// the second event waits for the retry of the first one
+	notifier.Notify(&WebhookEvent{Event: EventRestartStarted, ProposalID: 3})
+	notifier.Notify(&WebhookEvent{Event: EventRestartSucceeded, ProposalID: 3})
+	notifier.Wait()
+
+	var events []EventType
+	for _, r := range requests() {
+		event := &WebhookEvent{}
+		assert.Nil(t, json.Unmarshal(r.body, event))
+		events = append(events, event.Event)
+	}
+	assert.Equal(t, []EventType{EventRestartStarted, EventRestartStarted, EventRestartSucceeded}, events)
+}
+
+func TestNotifyResumedUpgrade(t *testing.T) {
+	server, requests := newWebhookReceiver(t, nil)
+
+	c := repo.DefaultConfig(t.TempDir())
+	c.Restart.Mode = repo.RestartModeNoop
+	c.HealthCheck.Rollback = true
+	c.HealthCheck.Timeout = 100 * time.Millisecond
+	c.HealthCheck.Interval = 10 * time.Millisecond
+	c.Webhooks = []repo.Webhook{{Name: "generic", URL: server.URL}}
+	client := &healthClient{version: "axiom/v1.1.0/linux-amd64/go1.20.5"}
+	guardian, err := NewGuardian(context.Background(), c, StaticClientFactory(client))
+	assert.Nil(t, err)
+
+	resume := func(id uint64) []WebhookEvent {
+		previousPath, _ := writeRelease(t, map[string]string{"axiom": "axiom v1.0.0", "version.sh": "echo 'Axiom version: v1.0.0'"})
+		upgrade := guardian.beginUpgrade(&NodeProposal{BaseProposal: BaseProposal{ID: id}, Version: "1.1.0"}, &Version{Major: 1})
+		upgrade.PreviousPath = previousPath
+		guardian.transition(upgrade, StateVerifying, nil)
+		// nothing is in progress after guardian restart
+		guardian.active.record = nil
+
+		before := len(requests())
+		_ = guardian.resumeUpgrade()
+		guardian.notifier.Wait()
+
+		var events []WebhookEvent
+		for _, r := range requests()[before:] {
+			event := WebhookEvent{}
+			assert.Nil(t, json.Unmarshal(r.body, &event))
+			events = append(events, event)
+		}
+		return events
+	}
+
+	events := resume(2)
+	assert.Len(t, events, 1)
+	assert.Equal(t, EventRestartSucceeded, events[0].Event)
+	assert.Equal(t, "1.0.0", events[0].FromVersion)
+	assert.Equal(t, "1.1.0", events[0].ToVersion)
+
+	// still on the previous version, rolled back
+	client.version = "axiom/v1.0.0/linux-amd64/go1.20.5"
+	events = resume(3)
+	assert.Len(t, events, 2)
+	assert.Equal(t, EventRestartFailed, events[0].Event)
+	assert.Equal(t, EventRolledBack, events[1].Event)
+	assert.Equal(t, uint64(3), events[1].ProposalID)
+}
+
+func TestNotifyProposalDetected(t *testing.T) {
+	server, requests := newWebhookReceiver(t, nil)
+
+	c := repo.DefaultConfig(t.TempDir())
+	c.Webhooks = []repo.Webhook{{Name: "generic", URL: server.URL}}
+	guardian, err := NewGuardian(context.Background(), c, StaticClientFactory(&MockClient{}))
+	assert.Nil(t, err)
+
+	// the same log fetched again after reconnect is notified once
+	log, err := generateLog()
+	assert.Nil(t, err)
+	guardian.handleProposalLog(log)
+	guardian.handleProposalLog(log)
+	guardian.notifier.Wait()
+
+	received := requests()
+	assert.Len(t, received, 1)
+	event := &WebhookEvent{}
+	assert.Nil(t, json.Unmarshal(received[0].body, event))
+	assert.Equal(t, EventProposalDetected, event.Event)
+	assert.Equal(t, uint64(1), event.ProposalID)
+
+	// versions come from the upgrade in progress
+	upgrade := guardian.beginUpgrade(&NodeProposal{BaseProposal: BaseProposal{ID: 2}, Version: "1.1.0"}, &Version{Major: 1})
+	guardian.transition(upgrade, StateRestarting, nil)
+	guardian.notify(EventRestartStarted, 2, "restart")
+	guardian.notifier.Wait()
+
+	received = requests()
+	assert.Len(t, received, 2)
+	assert.Nil(t, json.Unmarshal(received[1].body, event))
+	assert.Equal(t, "1.0.0", event.FromVersion)
+	assert.Equal(t, "1.1.0", event.ToVersion)
+
+	// applied before the last start
+	guardian.recordUpgradeProposal(5)
+	assert.False(t, guardian.firstDetected(4))
+}
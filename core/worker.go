@@ -0,0 +1,179 @@
+package core
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// QueuedProposal is an approved upgrade proposal waiting for the upgrade worker
+type QueuedProposal struct {
+	ProposalID uint64
+	Version    string
+	QueuedAt   int64
+}
+
+// WorkerStatus shows what the upgrade worker is doing
+type WorkerStatus struct {
+	// Busy is true while the worker plans or applies upgrades
+	Busy bool
+	// Paused is true if an operator paused upgrades
+	Paused bool
+	Queued []QueuedProposal
+}
+
+type queuedProposal struct {
+	proposal *NodeProposal
+	queuedAt int64
+}
+
+// upgradeQueue feeds approved proposals to the single upgrade worker
+type upgradeQueue struct {
+	lock    sync.Mutex
+	entries []*queuedProposal
+	busy    bool
+	// paused keeps the worker from applying upgrades, proposals are still queued
+	paused bool
+	// wake is signaled when proposals are queued, a burst of proposals is handled by one wake up
+	wake chan struct{}
+}
+
+func newUpgradeQueue(paused bool) *upgradeQueue {
+	return &upgradeQueue{paused: paused, wake: make(chan struct{}, 1)}
+}
+
+// push queues proposal, a queued proposal with the same id is replaced by the newer one
+func (q *upgradeQueue) push(proposal *NodeProposal) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	entry := &queuedProposal{proposal: proposal, queuedAt: time.Now().Unix()}
+	replaced := false
+	for i, e := range q.entries {
+		if e.proposal.ID == proposal.ID {
+			q.entries[i] = entry
+			replaced = true
+			break
+		}
+	}
+	if !replaced {
+		q.entries = append(q.entries, entry)
+		sort.Slice(q.entries, func(i, j int) bool {
+			return q.entries[i].proposal.ID < q.entries[j].proposal.ID
+		})
+	}
+
+	q.signal()
+}
+
+func (q *upgradeQueue) signal() {
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (q *upgradeQueue) setPaused(paused bool) {
+	q.lock.Lock()
+	q.paused = paused
+	q.lock.Unlock()
+
+	// the proposals queued while paused are applied on resume
+	if !paused {
+		q.signal()
+	}
+}
+
+func (q *upgradeQueue) isPaused() bool {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	return q.paused
+}
+
+// drain takes all queued proposals and marks the worker busy
+func (q *upgradeQueue) drain() []*NodeProposal {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	proposals := make([]*NodeProposal, 0, len(q.entries))
+	for _, e := range q.entries {
+		proposals = append(proposals, e.proposal)
+	}
+	q.entries = nil
+	q.busy = true
+
+	return proposals
+}
+
+func (q *upgradeQueue) done() {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	q.busy = false
+}
+
+func (q *upgradeQueue) status() *WorkerStatus {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	status := &WorkerStatus{Busy: q.busy, Paused: q.paused, Queued: []QueuedProposal{}}
+	for _, e := range q.entries {
+		status.Queued = append(status.Queued, QueuedProposal{
+			ProposalID: e.proposal.ID,
+			Version:    e.proposal.Version,
+			QueuedAt:   e.queuedAt,
+		})
+	}
+
+	return status
+}
+
+// WorkerStatus returns the state of the upgrade worker and its queue
+func (g *Guardian) WorkerStatus() *WorkerStatus {
+	return g.queue.status()
+}
+
+// PauseUpgrades stops applying upgrades after the hop in progress, until ResumeUpgrades.
+// Approved proposals are still queued, the pause survives restarts of guardian.
+func (g *Guardian) PauseUpgrades() {
+	g.DB.Put([]byte(upgradesPausedKey), []byte{1})
+	g.queue.setPaused(true)
+	g.Logger.Warn("upgrades paused by operator")
+}
+
+// ResumeUpgrades applies the proposals approved while paused
+func (g *Guardian) ResumeUpgrades() {
+	g.DB.Delete([]byte(upgradesPausedKey))
+	g.queue.setPaused(false)
+	g.Logger.Info("upgrades resumed by operator")
+}
+
+// upgradeWorker is the only goroutine which upgrades axiom, so stages of different proposals never interleave.
+//
+// Queued proposals are merged into the approved proposals at every wake up, then one planning run
+// applies the path to the newest version reachable, however many proposals arrived in the burst.
+func (g *Guardian) upgradeWorker() {
+	// finish the upgrade interrupted by the last exit before planning new ones
+	if err := g.resumeUpgrade(); err != nil {
+		g.Logger.Errorf("resume upgrade error: %s", err)
+	}
+
+	for {
+		proposals := g.queue.drain()
+		for _, proposal := range proposals {
+			g.addApprovedProposal(proposal)
+		}
+		if len(proposals) > 0 {
+			g.Logger.Infof("upgrade worker merges %d queued proposals", len(proposals))
+		}
+		g.downloadAndRestart()
+		g.queue.done()
+
+		select {
+		case <-g.Ctx.Done():
+			return
+		case <-g.queue.wake:
+		}
+	}
+}
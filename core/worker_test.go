@@ -0,0 +1,96 @@
+package core
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/axiomesh/guardian/repo"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUpgradeQueue(t *testing.T) {
+	q := newUpgradeQueue(false)
+	q.push(&NodeProposal{BaseProposal: BaseProposal{ID: 3}, Version: "1.3.0"})
+	q.push(&NodeProposal{BaseProposal: BaseProposal{ID: 1}, Version: "1.1.0"})
+	q.push(&NodeProposal{BaseProposal: BaseProposal{ID: 3}, Version: "1.3.1"})
+
+	// a burst wakes the worker once
+	assert.Equal(t, 1, len(q.wake))
+
+	status := q.status()
+	assert.False(t, status.Busy)
+	assert.Equal(t, 2, len(status.Queued))
+	assert.Equal(t, uint64(1), status.Queued[0].ProposalID)
+	assert.Equal(t, "1.3.1", status.Queued[1].Version)
+
+	proposals := q.drain()
+	assert.Equal(t, 2, len(proposals))
+	status = q.status()
+	assert.True(t, status.Busy)
+	assert.Empty(t, status.Queued)
+
+	q.done()
+	assert.False(t, q.status().Busy)
+}
+
+func TestUpgradeWorker(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	c := repo.DefaultConfig(t.TempDir())
+	c.AxiomPath = t.TempDir()
+	guardian, err := NewGuardian(ctx, c, StaticClientFactory(&MockClient{}))
+	assert.Nil(t, err)
+
+	exited := make(chan struct{})
+	go func() {
+		guardian.upgradeWorker()
+		close(exited)
+	}()
+
+	for id := uint64(1); id <= 3; id++ {
+		guardian.queue.push(&NodeProposal{BaseProposal: BaseProposal{ID: id, Type: NodeUpgrade, Status: Approved}})
+	}
+	assert.Eventually(t, func() bool {
+		status := guardian.WorkerStatus()
+		return !status.Busy && len(status.Queued) == 0
+	}, time.Second, 10*time.Millisecond)
+
+	cancel()
+	select {
+	case <-exited:
+	case <-time.After(time.Second):
+		t.Fatal("upgrade worker not exit after context done")
+	}
+	assert.Equal(t, 3, len(guardian.approvedProposals))
+}
+
+func TestRestoreApprovedProposals(t *testing.T) {
+	c := repo.DefaultConfig(t.TempDir())
+	guardian, err := NewGuardian(context.Background(), c, StaticClientFactory(&MockClient{}))
+	assert.Nil(t, err)
+
+	// queued while paused, the checkpoint moves past it
+	guardian.PauseUpgrades()
+	log, err := generateLog()
+	assert.Nil(t, err)
+	log.BlockNumber = 100
+	guardian.handleProposalLog(log)
+	assert.Equal(t, uint64(100), guardian.Checkpoint())
+	assert.Nil(t, guardian.DB.Close())
+
+	// restarted, the log before the checkpoint is not fetched again
+	guardian, err = NewGuardian(context.Background(), c, StaticClientFactory(&MockClient{}))
+	assert.Nil(t, err)
+	assert.Nil(t, guardian.restoreApprovedProposals())
+	status := guardian.WorkerStatus()
+	assert.True(t, status.Paused)
+	assert.Equal(t, 1, len(status.Queued))
+	assert.Equal(t, uint64(1), status.Queued[0].ProposalID)
+
+	// dropped once applied
+	guardian.recordUpgradeProposal(1)
+	guardian.queue.drain()
+	assert.Nil(t, guardian.restoreApprovedProposals())
+	assert.Empty(t, guardian.WorkerStatus().Queued)
+	assert.Nil(t, guardian.DB.Get([]byte(approvedProposalKeyPrefix+"1")))
+}
@@ -5,11 +5,36 @@ import (
 )
 
 type Config struct {
-	RepoRoot  string    `mapstructure:"-" toml:"-"`
-	DialUrl   string    `mapstructure:"dial_url" toml:"dial_url"`
-	AxiomPath string    `mapstructure:"axiom_path" toml:"axiom_path"`
-	Log       Log       `mapstructure:"log" toml:"log"`
-	Subscribe Subscribe `mapstructure:"subscribe" toml:"subscribe"`
+	RepoRoot        string        `mapstructure:"-" toml:"-"`
+	DialUrl         string        `mapstructure:"dial_url" toml:"dial_url"`
+	AxiomPath       string        `mapstructure:"axiom_path" toml:"axiom_path"`
+	DryRun          bool          `mapstructure:"dry_run" toml:"dry_run"`
+	Network         Network       `mapstructure:"network" toml:"network"`
+	ShutdownTimeout time.Duration `mapstructure:"shutdown_timeout" toml:"shutdown_timeout"`
+	Log             Log           `mapstructure:"log" toml:"log"`
+	Subscribe       Subscribe     `mapstructure:"subscribe" toml:"subscribe"`
+	Reconnect       Reconnect     `mapstructure:"reconnect" toml:"reconnect"`
+	Release         Release       `mapstructure:"release" toml:"release"`
+	Restart         Restart       `mapstructure:"restart" toml:"restart"`
+	HealthCheck     HealthCheck   `mapstructure:"health_check" toml:"health_check"`
+	Snapshot        Snapshot      `mapstructure:"snapshot" toml:"snapshot"`
+	Hooks           Hooks         `mapstructure:"hooks" toml:"hooks"`
+	Approval        Approval      `mapstructure:"approval" toml:"approval"`
+	Policy          Policy        `mapstructure:"policy" toml:"policy"`
+	Metrics         Metrics       `mapstructure:"metrics" toml:"metrics"`
+	Admin           Admin         `mapstructure:"admin" toml:"admin"`
+	Probe           Probe         `mapstructure:"probe" toml:"probe"`
+	Audit           Audit         `mapstructure:"audit" toml:"audit"`
+	Webhooks        []Webhook     `mapstructure:"webhooks" toml:"webhooks"`
+	Debug           Debug         `mapstructure:"debug" toml:"debug"`
+}
+
+// Network is checked on every connect, guardian refuses to act on proposals of another network
+type Network struct {
+	// expected eth_chainId of axiom, 0 means not check
+	ChainID uint64 `mapstructure:"chain_id" toml:"chain_id"`
+	// expected keccak256 hash of the node manager contract code, empty means not check
+	NodeManagerCodeHash string `mapstructure:"node_manager_code_hash" toml:"node_manager_code_hash"`
 }
 
 type Log struct {
@@ -35,11 +60,211 @@ type Subscribe struct {
 	Topics [][]string `mapstructure:"topics" toml:"topics"`
 }
 
+// Reconnect retries to connect axiom until it succeeds, the delay doubles on every failure with a random jitter
+type Reconnect struct {
+	MinBackoff time.Duration `mapstructure:"min_backoff" toml:"min_backoff"`
+	MaxBackoff time.Duration `mapstructure:"max_backoff" toml:"max_backoff"`
+}
+
+type Release struct {
+	// keep releases under <axiom_path>/releases/<version> and run the one <axiom_path>/current links to
+	Managed bool `mapstructure:"managed" toml:"managed"`
+}
+
+const (
+	// RestartModeScript restarts axiom by restart.sh under the axiom path
+	RestartModeScript = "script"
+	// RestartModeSupervisor runs axiom as a child process of guardian
+	RestartModeSupervisor = "supervisor"
+	// RestartModeSystemd restarts the systemd unit of axiom
+	RestartModeSystemd = "systemd"
+	// RestartModeDocker restarts the docker container of axiom
+	RestartModeDocker = "docker"
+	// RestartModeNoop only logs the restart
+	RestartModeNoop = "noop"
+)
+
+type Restart struct {
+	// one of script, supervisor, systemd, docker and noop
+	Mode       string     `mapstructure:"mode" toml:"mode"`
+	Supervisor Supervisor `mapstructure:"supervisor" toml:"supervisor"`
+	Systemd    Systemd    `mapstructure:"systemd" toml:"systemd"`
+	Docker     Docker     `mapstructure:"docker" toml:"docker"`
+}
+
+// Supervisor runs axiom as a child process of guardian instead of restarting it by restart.sh
+type Supervisor struct {
+	// arguments passed to the axiom binary
+	Args []string `mapstructure:"args" toml:"args"`
+	// time to wait after SIGTERM before killing axiom
+	StopTimeout time.Duration `mapstructure:"stop_timeout" toml:"stop_timeout"`
+	// file under the logs directory which captures axiom stdout and stderr
+	LogFile string `mapstructure:"log_file" toml:"log_file"`
+	// restart delay after a crash, doubled on every crash up to max
+	MinRestartBackoff time.Duration `mapstructure:"min_restart_backoff" toml:"min_restart_backoff"`
+	MaxRestartBackoff time.Duration `mapstructure:"max_restart_backoff" toml:"max_restart_backoff"`
+}
+
+type Systemd struct {
+	// unit name of axiom, e.g. axiom.service
+	Unit string `mapstructure:"unit" toml:"unit"`
+	// path of the systemctl command
+	Systemctl string `mapstructure:"systemctl" toml:"systemctl"`
+}
+
+type Docker struct {
+	// unix socket of the docker engine api
+	Socket string `mapstructure:"socket" toml:"socket"`
+	// name or id of the axiom container, the axiom path should be mounted into it
+	Container string `mapstructure:"container" toml:"container"`
+	// time to wait for the container to stop before killing it
+	StopTimeout time.Duration `mapstructure:"stop_timeout" toml:"stop_timeout"`
+}
+
+// HealthCheck verifies axiom after an upgrade restart
+type HealthCheck struct {
+	// deadline for the rpc to come back and report the staged version
+	Timeout time.Duration `mapstructure:"timeout" toml:"timeout"`
+	// deadline for the block height to advance after the rpc is back
+	BlockAdvanceTimeout time.Duration `mapstructure:"block_advance_timeout" toml:"block_advance_timeout"`
+	// minimum peer count of the restarted node, 0 means not check
+	MinPeers uint64 `mapstructure:"min_peers" toml:"min_peers"`
+	// interval between two rpc probes
+	Interval time.Duration `mapstructure:"interval" toml:"interval"`
+	// reinstall the previous binary if the health check fails
+	Rollback bool `mapstructure:"rollback" toml:"rollback"`
+}
+
+const (
+	// SnapshotModeCopy snapshots the data directory by a copy of its files
+	SnapshotModeCopy = "copy"
+	// SnapshotModeHardlink is the former name of SnapshotModeCopy, it is taken as copy.
+	// Hard links shared the files axiom keeps writing in place, so they did not keep the state before upgrade.
+	SnapshotModeHardlink = "hardlink"
+	// SnapshotModeArchive snapshots the data directory into a tar.gz archive
+	SnapshotModeArchive = "archive"
+)
+
+// Snapshot backs up the axiom data directory before an upgrade restart
+type Snapshot struct {
+	Enable bool `mapstructure:"enable" toml:"enable"`
+	// axiom data directory
+	DataPath string `mapstructure:"data_path" toml:"data_path"`
+	// directory to keep snapshots, empty means the snapshots directory under the repo root
+	BackupPath string `mapstructure:"backup_path" toml:"backup_path"`
+	// one of copy and archive
+	Mode string `mapstructure:"mode" toml:"mode"`
+	// number of snapshots to keep, 0 means keep all
+	Retain int `mapstructure:"retain" toml:"retain"`
+}
+
+// Hooks are commands run around an upgrade, a failing hook of the phases before restart aborts the upgrade
+type Hooks struct {
+	// before the release is downloaded
+	PreDownload []Hook `mapstructure:"pre_download" toml:"pre_download"`
+	// after the release is downloaded and verified
+	PostVerify []Hook `mapstructure:"post_verify" toml:"post_verify"`
+	// before axiom is restarted with the new release
+	PreRestart []Hook `mapstructure:"pre_restart" toml:"pre_restart"`
+	// after the restarted axiom passes the health check, failure is only reported
+	PostRestart []Hook `mapstructure:"post_restart" toml:"post_restart"`
+}
+
+// Hook is executed by bash with the upgrade context in json on stdin
+type Hook struct {
+	Name    string        `mapstructure:"name" toml:"name"`
+	Command string        `mapstructure:"command" toml:"command"`
+	Timeout time.Duration `mapstructure:"timeout" toml:"timeout"`
+}
+
+// Approval holds verified releases until an operator approves the restart
+type Approval struct {
+	Enable bool `mapstructure:"enable" toml:"enable"`
+	// approve automatically if no operator decides in time, 0 means wait forever
+	AutoApproveTimeout time.Duration `mapstructure:"auto_approve_timeout" toml:"auto_approve_timeout"`
+}
+
+// Policy is the local upgrade policy, it refuses releases even if the chain approved them
+type Policy struct {
+	// stay on this version, only an upgrade to it is allowed, empty means not pinned
+	PinnedVersion string `mapstructure:"pinned_version" toml:"pinned_version"`
+	// versions never upgraded to
+	DeniedVersions []string `mapstructure:"denied_versions" toml:"denied_versions"`
+	// sha256 digests of release packages, patches or binaries never installed
+	DeniedDigests []string `mapstructure:"denied_digests" toml:"denied_digests"`
+	// version constraints, e.g. ">=1.2.0 <2.0.0", the new version must satisfy one of them, empty means any
+	AllowedRanges []string `mapstructure:"allowed_ranges" toml:"allowed_ranges"`
+}
+
+// Metrics serves guardian metrics in the prometheus text format on /metrics
+type Metrics struct {
+	Enable bool `mapstructure:"enable" toml:"enable"`
+	// tcp address of the metrics listener, e.g. 127.0.0.1:9400
+	ListenAddr string `mapstructure:"listen_addr" toml:"listen_addr"`
+}
+
+// Admin is the admin api, it is always served on the control socket and also on a localhost tcp address if set.
+// Every request must carry the token in the admin token file under the repo root.
+type Admin struct {
+	// loopback tcp address of the admin api, e.g. 127.0.0.1:9401, empty means only the control socket
+	ListenAddr string `mapstructure:"listen_addr" toml:"listen_addr"`
+}
+
+// Probe serves the liveness check on /healthz and the readiness check on /readyz for container orchestrators
+type Probe struct {
+	Enable bool `mapstructure:"enable" toml:"enable"`
+	// tcp address of the probe listener, the orchestrator probes from outside of the container
+	ListenAddr string `mapstructure:"listen_addr" toml:"listen_addr"`
+	// guardian is not alive if the event loop does not run for this long
+	LoopTimeout time.Duration `mapstructure:"loop_timeout" toml:"loop_timeout"`
+}
+
+// Audit appends every finished upgrade to a hash chained audit log under the repo root
+type Audit struct {
+	Enable bool `mapstructure:"enable" toml:"enable"`
+}
+
+const (
+	// WebhookFormatGeneric posts the event in json
+	WebhookFormatGeneric = "generic"
+	// WebhookFormatSlack posts a slack incoming webhook message
+	WebhookFormatSlack = "slack"
+)
+
+// Webhook is notified of upgrade lifecycle events
+type Webhook struct {
+	Name string `mapstructure:"name" toml:"name"`
+	URL  string `mapstructure:"url" toml:"url"`
+	// one of generic and slack
+	Format string `mapstructure:"format" toml:"format"`
+	// events posted to the webhook, empty means all
+	Events []string `mapstructure:"events" toml:"events"`
+	// key of the hmac-sha256 signature of requests, empty means not signed
+	Secret string `mapstructure:"secret" toml:"secret"`
+	// retries after the first failed request
+	Retries int `mapstructure:"retries" toml:"retries"`
+	// timeout of a request
+	Timeout time.Duration `mapstructure:"timeout" toml:"timeout"`
+}
+
+// Debug serves pprof, expvar and a goroutine dump for troubleshooting
+type Debug struct {
+	Enable bool `mapstructure:"enable" toml:"enable"`
+	// loopback tcp address of the debug listener
+	ListenAddr string `mapstructure:"listen_addr" toml:"listen_addr"`
+}
+
 func DefaultConfig(repoRoot string) *Config {
 	return &Config{
-		RepoRoot: repoRoot,
-		DialUrl:  "ws://localhost:9991",
-		AxiomPath: "~/.axiom",
+		RepoRoot:        repoRoot,
+		DialUrl:         "ws://localhost:9991",
+		AxiomPath:       "~/.axiom",
+		DryRun:          false,
+		ShutdownTimeout: 2 * time.Minute,
+		Network: Network{
+			ChainID:             0,
+			NodeManagerCodeHash: "",
+		},
 		Log: Log{
 			Level:        "info",
 			Filename:     "guardian.log",
@@ -54,5 +279,75 @@ func DefaultConfig(repoRoot string) *Config {
 			// first position is vote method signature's 32 Byte hash, second postion is {} to mean any topic, third is proposal type's hash for update axiom
 			Topics: [][]string{{"0xe6bfc3cff2e28bc2ab583f413a459f93526e55a1a46c944572150de96997c84e"}, {}, {"0x0000000000000000000000000000000000000000000000000000000000000001"}},
 		},
+		Reconnect: Reconnect{
+			MinBackoff: time.Second,
+			MaxBackoff: time.Minute,
+		},
+		Release: Release{
+			Managed: false,
+		},
+		Restart: Restart{
+			Mode: RestartModeScript,
+			Supervisor: Supervisor{
+				Args:              []string{"start"},
+				StopTimeout:       30 * time.Second,
+				LogFile:           "axiom.log",
+				MinRestartBackoff: time.Second,
+				MaxRestartBackoff: time.Minute,
+			},
+			Systemd: Systemd{
+				Unit:      "axiom.service",
+				Systemctl: "systemctl",
+			},
+			Docker: Docker{
+				Socket:      "/var/run/docker.sock",
+				Container:   "axiom",
+				StopTimeout: 30 * time.Second,
+			},
+		},
+		HealthCheck: HealthCheck{
+			Timeout:             2 * time.Minute,
+			BlockAdvanceTimeout: 2 * time.Minute,
+			MinPeers:            0,
+			Interval:            5 * time.Second,
+			Rollback:            true,
+		},
+		Snapshot: Snapshot{
+			Enable:     false,
+			DataPath:   "~/.axiom/storage",
+			BackupPath: "",
+			Mode:       SnapshotModeArchive,
+			Retain:     3,
+		},
+		Approval: Approval{
+			Enable:             false,
+			AutoApproveTimeout: 0,
+		},
+		Policy: Policy{
+			PinnedVersion:  "",
+			DeniedVersions: []string{},
+			DeniedDigests:  []string{},
+			AllowedRanges:  []string{},
+		},
+		Metrics: Metrics{
+			Enable:     false,
+			ListenAddr: "127.0.0.1:9400",
+		},
+		Admin: Admin{
+			ListenAddr: "",
+		},
+		Probe: Probe{
+			Enable:      false,
+			ListenAddr:  ":9402",
+			LoopTimeout: 2 * time.Minute,
+		},
+		Audit: Audit{
+			Enable: true,
+		},
+		Webhooks: []Webhook{},
+		Debug: Debug{
+			Enable:     false,
+			ListenAddr: "127.0.0.1:9403",
+		},
 	}
 }
@@ -23,6 +23,20 @@ const (
 
 	LogsDirName = "logs"
 
+	SnapshotsDirName = "snapshots"
+
+	// ControlSocketName is the unix socket under the repo root for operators to control the running guardian
+	ControlSocketName = "guardian.sock"
+
+	// AdminTokenName is the file under the repo root holding the token of the admin api
+	AdminTokenName = "admin.token"
+
+	// AuditLogName is the audit log under the repo root, one json entry per line
+	AuditLogName = "audit.jsonl"
+
+	// AuditHeadName is the file under the repo root holding the sequence and hash of the last audit entry
+	AuditHeadName = "audit.head"
+
 	NodeManagerContractAddr = "0x0000000000000000000000000000000000001001"
 )
 
@@ -101,11 +115,13 @@ func writeConfig(cfgPath string, config any) error {
 		return err
 	}
 
-	if err := os.WriteFile(cfgPath, []byte(raw), 0755); err != nil {
+	// write a temporary file and rename it, a reader never sees a half-written config
+	tmpPath := cfgPath + ".tmp"
+	if err := os.WriteFile(tmpPath, []byte(raw), 0755); err != nil {
 		return err
 	}
 
-	return nil
+	return os.Rename(tmpPath, cfgPath)
 }
 
 func MarshalConfig(config any) (string, error) {